@@ -5,13 +5,31 @@ import (
 	"net/http"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/google/uuid"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey        = contextKey("user")
+	requestInfoContextKey = contextKey("request_info")
+)
+
+// requestInfo is filled in while a request goes through the router and the
+// authentication, for the middlewares which run before and log the request.
+// The route of a request which doesn't match any route is unmatchedRoute.
+type requestInfo struct {
+	ID    string
+	Route string
+	User  *data.User
+	Team  uuid.UUID
+}
 
 func (app *Application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if info := app.contextGetRequestInfo(r); info != nil {
+		info.User = user
+	}
+
 	ctx := context.WithValue(r.Context(), userContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -24,3 +42,23 @@ func (app *Application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+func (app *Application) contextSetRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestInfo returns nil outside of the middleware chain of Routes
+func (app *Application) contextGetRequestInfo(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	return info
+}
+
+// contextGetRequestID returns the X-Request-ID of the request
+func (app *Application) contextGetRequestID(r *http.Request) string {
+	if info := app.contextGetRequestInfo(r); info != nil {
+		return info.ID
+	}
+
+	return ""
+}
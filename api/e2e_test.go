@@ -10,9 +10,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
 	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,11 +26,17 @@ func TestE2E(t *testing.T) {
 	cfg.Db.MaxOpenConn = 25
 	cfg.Db.MaxIdleConn = 25
 	cfg.Db.MaxIdleTime = "15m"
+	cfg.Db.QueryTimeout = 3 * time.Second
 	cfg.Limiter.Enabled = true
 	cfg.Limiter.Rps = 2
 	cfg.Limiter.Burst = 6
 	cfg.GRPCTeam = "localhost:5001"
 	cfg.Uploads = "../local/test/uploads"
+	cfg.Picture.MaxWidth = 4096
+	cfg.Picture.MaxHeight = 4096
+	cfg.Picture.MaxUploadSize = 10_485_760
+	cfg.Health.Timeout = time.Second
+	cfg.Idempotency.TTL = time.Hour
 
 	// Set logger
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
@@ -44,7 +52,10 @@ func TestE2E(t *testing.T) {
 	app := Application{
 		Config: cfg,
 		Logger: logger,
-		Models: data.InitModels(db),
+		DB:     db,
+		Models: data.InitModels(db, cfg.Db.QueryTimeout),
+
+		Storage: storage.NewFilesystem(cfg.Uploads),
 	}
 
 	// Server Routes API
@@ -246,17 +257,23 @@ func TestE2E(t *testing.T) {
 	assert.Equal(t, res.StatusCode, http.StatusOK)
 
 	// Read response
-	var mTeamMembers map[string][]data.TeamMember
+	var mTeamMembers struct {
+		TeamMembers []data.TeamMember `json:"team_members"`
+		Metadata    data.Metadata     `json:"metadata"`
+	}
 	err = json.NewDecoder(res.Body).Decode(&mTeamMembers)
 	assert.Nil(t, err)
 
 	// Should be more than 0
-	assert.NotEqual(t, len(mTeamMembers["team_members"]), 0)
+	assert.NotEqual(t, len(mTeamMembers.TeamMembers), 0)
+	assert.Equal(t, mTeamMembers.Metadata.TotalRecords, len(mTeamMembers.TeamMembers))
+	assert.Equal(t, mTeamMembers.Metadata.CurrentPage, 1)
+	assert.Equal(t, mTeamMembers.Metadata.PageSize, 20)
 
 	// Get a team member
 	req, _ = http.NewRequest(
 		"GET",
-		ts.URL+"/service/teams/members/"+mTeamMembers["team_members"][0].ID.String(),
+		ts.URL+"/service/teams/members/"+mTeamMembers.TeamMembers[0].ID.String(),
 		nil)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Set("Authorization", bearer)
@@ -1,27 +1,66 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
 )
 
 func (app *Application) logError(r *http.Request, err error) {
-	app.Logger.PrintError(err, map[string]string{
-		"request_method": r.Method,
-		"request_url":    r.URL.String(),
-	})
+	app.requestLogger(r).Error(err,
+		jsonlog.String("request_method", r.Method),
+		jsonlog.String("request_url", r.URL.String()),
+	)
 }
 
 func (app *Application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
 
-	err := app.writeJSON(w, status, env, nil)
+	var headers http.Header
+
+	// A client asking for it gets an RFC 7807 problem,
+	// the messages of the fields are in its errors member
+	if acceptsProblem(r) {
+		env = envelope{
+			"type":   "about:blank",
+			"title":  http.StatusText(status),
+			"status": status,
+		}
+
+		switch message := message.(type) {
+		case string:
+			env["detail"] = message
+		default:
+			env["errors"] = message
+		}
+
+		headers = http.Header{"Content-Type": []string{"application/problem+json"}}
+	}
+
+	err := app.writeJSON(w, status, env, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
 	}
 }
 
+// acceptsProblem reports whether the request accepts application/problem+json
+func acceptsProblem(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == "application/problem+json" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (app *Application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
@@ -47,11 +86,41 @@ func (app *Application) failedValidationResponse(w http.ResponseWriter, r *http.
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
 }
 
+func (app *Application) requestTooLargeResponse(w http.ResponseWriter, r *http.Request, maxBytes int64) {
+	message := fmt.Sprintf("the request body must not be larger than %d bytes", maxBytes)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
 func (app *Application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *Application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has changed since it was read, please read it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *Application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the If-Match header is required to update this resource"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
+}
+
+func (app *Application) idempotencyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a request with the same Idempotency-Key is still being processed, please try again later"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *Application) idempotencyMismatchResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the Idempotency-Key has already been used for a different request"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *Application) ownerCannotLeaveResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the owner can't leave the team, transfer the ownership of the team first"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -84,7 +153,21 @@ func (app *Application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 
-func (app *Application) errSQLResponse(w http.ResponseWriter, r *http.Request, err error) {
-	message := err.Error()
-	app.errorResponse(w, r, http.StatusBadRequest, message)
+// constraintViolationResponse sends the response of a record which violates
+// a constraint of the database, with the message of the field in violation
+func (app *Application) constraintViolationResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var constraintError *data.ConstraintError
+	if !errors.As(err, &constraintError) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case errors.Is(err, data.ErrCreateConflict):
+		app.errorResponse(w, r, http.StatusConflict, map[string]string{constraintError.Field: "already exists"})
+	case errors.Is(err, data.ErrInvalidReference):
+		app.failedValidationResponse(w, r, map[string]string{constraintError.Field: "does not exist"})
+	default:
+		app.failedValidationResponse(w, r, map[string]string{constraintError.Field: "is invalid"})
+	}
 }
@@ -1,7 +1,17 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func (app *Application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -18,3 +28,129 @@ func (app *Application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readinessProbe is the file written to check the storage of the uploads,
+// it isn't a picture name so the sweeper doesn't look at it
+const readinessProbe = ".readiness"
+
+// dependencyCheck is a dependency checked by the readiness probe,
+// a failed critical check makes the service unavailable
+type dependencyCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+func (app *Application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	// The process is up and serving, the dependencies are left to the readiness
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "alive"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	// The indexing events wait in the outbox while
+	// the indexing service is down, so it isn't critical
+	checks := []dependencyCheck{
+		{name: "database", critical: true, check: app.checkDatabase},
+		{name: "storage", critical: true, check: app.checkStorage},
+		{name: "indexing", critical: false, check: app.checkIndexing},
+	}
+
+	// Run the checks at once, each within the timeout
+	statuses := make(map[string]*dependencyStatus, len(checks))
+	errs := make([]error, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		statuses[c.name] = &dependencyStatus{Critical: c.critical}
+
+		wg.Add(1)
+		go func(i int, c dependencyCheck, s *dependencyStatus) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), app.Config.Health.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			errs[i] = c.check(ctx)
+			s.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+		}(i, c, statuses[c.name])
+	}
+
+	wg.Wait()
+
+	status, code := "available", http.StatusOK
+
+	for i, c := range checks {
+		if errs[i] == nil {
+			statuses[c.name].Status = "up"
+			continue
+		}
+
+		statuses[c.name].Status = "down"
+		app.requestLogger(r).Warn("dependency check failed",
+			jsonlog.String("dependency", c.name),
+			jsonlog.String("error", errs[i].Error()),
+		)
+
+		if c.critical {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		} else if status == "available" {
+			status = "degraded"
+		}
+	}
+
+	err := app.writeJSON(w, code, envelope{"status": status, "checks": statuses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) checkDatabase(ctx context.Context) error {
+	if app.DB == nil {
+		return errors.New("no database connection pool")
+	}
+
+	return app.DB.PingContext(ctx)
+}
+
+func (app *Application) checkStorage(ctx context.Context) error {
+	err := app.Storage.Put(ctx, readinessProbe, strings.NewReader("ok"), 2, "text/plain")
+	if err != nil {
+		return err
+	}
+
+	// Another probe running at the same time may have deleted the file
+	err = app.Storage.Delete(ctx, readinessProbe)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (app *Application) checkIndexing(ctx context.Context) error {
+	if app.IndexingHealth == nil {
+		return errors.New("no indexing service connection")
+	}
+
+	res, err := app.IndexingHealth.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	if res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("indexing service is %s", res.Status)
+	}
+
+	return nil
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/go-playground/form"
 
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
 	"github.com/e-inwork-com/go-team-service/internal/validator"
 
 	"github.com/google/uuid"
@@ -36,10 +38,10 @@ func (app *Application) readFileParam(r *http.Request) (string, error) {
 	// Get param from request
 	params := httprouter.ParamsFromContext(r.Context())
 
-	// Get file from the request params,
-	// and parse it to the valid UUID
+	// Get file from the request params, only a name given
+	// to a picture is valid, even by the previous uploads
 	file := params.ByName("file")
-	if file == "" {
+	if !picture.ValidName(file) && !picture.LegacyName(file) {
 		return "", errors.New("invalid file parameter")
 	}
 
@@ -60,7 +62,9 @@ func (app *Application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if headers.Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	w.Write(js)
 
@@ -178,6 +182,68 @@ func (app *Application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// teamETag is the entity tag of a team, it changes with every update of the team
+func teamETag(team *data.Team) string {
+	return fmt.Sprintf(`"%s-%d"`, team.ID, team.Version)
+}
+
+// teamHeaders are the headers of a response holding a team
+func teamHeaders(team *data.Team) http.Header {
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	return headers
+}
+
+// ifMatch reports whether the If-Match header of the request matches the
+// entity tag, otherwise it sends an error. A weak tag never matches.
+func (app *Application) ifMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		if app.Config.Concurrency.RequireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+
+		return true
+	}
+
+	for _, tag := range strings.Split(strings.Join(values, ","), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	// Let the client know the current version
+	w.Header().Set("ETag", etag)
+	app.preconditionFailedResponse(w, r)
+
+	return false
+}
+
+// teamChangedResponse sends the response of a write whose team changed after it
+// was read. The precondition of a conditional request doesn't hold anymore,
+// so it fails with the current entity tag, like the check of ifMatch.
+func (app *Application) teamChangedResponse(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.Header.Get("If-Match") == "" {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	// A team deleted in the meantime has no tag anymore
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if team != nil {
+		w.Header().Set("ETag", teamETag(team))
+	}
+	app.preconditionFailedResponse(w, r)
+}
+
 func (app *Application) background(fn func()) {
 	app.wg.Add(1)
 
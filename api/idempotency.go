@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+)
+
+// How long a request holds its key, a key left behind by
+// a stopped replica can be claimed again afterwards
+const idempotencyKeyLease = time.Minute
+
+// idempotencyKeyRX matches the visible ASCII keys, like the UUIDs of the clients
+var idempotencyKeyRX = regexp.MustCompile(`^[\x21-\x7E]{1,255}$`)
+
+// idempotencyHeaders are the headers of a response which are replayed
+var idempotencyHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotencyBody fingerprints a request body while it is read
+type idempotencyBody struct {
+	io.Reader
+	io.Closer
+}
+
+// idempotencyRecorder keeps a copy of the response to replay it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	rec.body.Write(b)
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent handles a request with an Idempotency-Key header once per user
+// and key, a retry of the request gets the original response. The request is
+// identified by its method, its path and its body, without the boundary of
+// a multipart body which changes with every retry.
+func (app *Application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("Idempotency-Key")
+		if value == "" {
+			next(w, r)
+			return
+		}
+
+		if !idempotencyKeyRX.MatchString(value) {
+			app.badRequestResponse(w, r, errors.New("invalid Idempotency-Key header"))
+			return
+		}
+
+		key := &data.IdempotencyKey{Key: value, User: app.contextGetUser(r).ID}
+
+		claimed, err := app.Models.IdempotencyKeys.Claim(r.Context(), key, app.Config.Idempotency.TTL, idempotencyKeyLease)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !claimed {
+			app.replayIdempotentResponse(w, r, key)
+			return
+		}
+
+		// Fingerprint the body while the handler reads it, and then the rest of it
+		fingerprint := newIdempotencyFingerprint(r)
+		body := r.Body
+		r.Body = idempotencyBody{Reader: io.TeeReader(body, fingerprint), Closer: body}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		_, err = io.Copy(fingerprint, io.LimitReader(body, app.Config.Picture.MaxUploadSize))
+		if err != nil {
+			rec.status = http.StatusInternalServerError
+		}
+
+		// The response is stored even if the client is gone,
+		// the client is likely to retry the request
+		ctx := context.WithoutCancel(r.Context())
+
+		// A failed request can be retried with the same key
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			err = app.Models.IdempotencyKeys.Release(ctx, key)
+			if err != nil {
+				app.logError(r, err)
+			}
+			return
+		}
+
+		key.RequestHash = fingerprint.Sum()
+		key.ResponseStatus = rec.status
+		key.ResponseHeaders = make(map[string][]string)
+		for _, name := range idempotencyHeaders {
+			if values := rec.Header().Values(name); len(values) > 0 {
+				key.ResponseHeaders[name] = values
+			}
+		}
+		key.ResponseBody = rec.body.Bytes()
+
+		err = app.Models.IdempotencyKeys.Complete(ctx, key)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// idempotencyFingerprint is the hash of a request, the body is written to it.
+// The boundary of a multipart body is left out of the hash, the part of the
+// body which may start a boundary is held until the next write.
+type idempotencyFingerprint struct {
+	hash     hash.Hash
+	boundary []byte
+	pending  []byte
+}
+
+// newIdempotencyFingerprint returns the fingerprint of a request, to be completed with its body
+func newIdempotencyFingerprint(r *http.Request) *idempotencyFingerprint {
+	fingerprint := &idempotencyFingerprint{hash: sha256.New()}
+	io.WriteString(fingerprint.hash, r.Method+" "+r.URL.Path+"\n")
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		fingerprint.boundary = []byte(params["boundary"])
+	}
+
+	return fingerprint
+}
+
+func (f *idempotencyFingerprint) Write(b []byte) (int, error) {
+	if f.boundary == nil {
+		return f.hash.Write(b)
+	}
+
+	f.pending = append(f.pending, b...)
+
+	for {
+		i := bytes.Index(f.pending, f.boundary)
+		if i < 0 {
+			break
+		}
+
+		f.hash.Write(f.pending[:i])
+		f.pending = f.pending[i+len(f.boundary):]
+	}
+
+	if n := len(f.pending) - len(f.boundary) + 1; n > 0 {
+		f.hash.Write(f.pending[:n])
+		f.pending = f.pending[n:]
+	}
+
+	return len(b), nil
+}
+
+// Sum returns the hash of the request and the body written so far
+func (f *idempotencyFingerprint) Sum() []byte {
+	f.hash.Write(f.pending)
+	f.pending = nil
+
+	return f.hash.Sum(nil)
+}
+
+func (app *Application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key *data.IdempotencyKey) {
+	// The first request is still being handled
+	if !key.Handled() {
+		app.idempotencyInProgressResponse(w, r)
+		return
+	}
+
+	// The key must not be used for another request
+	fingerprint := newIdempotencyFingerprint(r)
+
+	_, err := io.Copy(fingerprint, io.LimitReader(r.Body, app.Config.Picture.MaxUploadSize))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !bytes.Equal(fingerprint.Sum(), key.RequestHash) {
+		app.idempotencyMismatchResponse(w, r)
+		return
+	}
+
+	app.requestLogger(r).Debug("idempotent request replayed", jsonlog.String("idempotency_key", key.Key))
+
+	for name, values := range key.ResponseHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	w.WriteHeader(key.ResponseStatus)
+	w.Write(key.ResponseBody)
+}
+
+// purgeIdempotencyKeys periodically deletes the expired idempotency keys
+func (app *Application) purgeIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Purge.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := app.Models.IdempotencyKeys.DeleteExpired(ctx)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task": "purge idempotency keys",
+				})
+			}
+		}
+	}
+}
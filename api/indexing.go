@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// How long a claimed event stays locked for the other replicas
+	teamEventLease = time.Minute
+
+	// Upper bound of the delay between two delivery attempts
+	teamEventMaxBackoff = 10 * time.Minute
+)
+
+func (app *Application) gRPCTeamIndexing(ctx context.Context, operation teams.Operation, team *data.Team) error {
+	// Set the team document, the version lets the indexing
+	// service discard writes that arrive out of order
+	teamEntry := &teams.Team{
+		Id:      team.ID.String(),
+		Version: int64(team.IndexVersion),
+	}
+
+	if operation != teams.Operation_OPERATION_DELETE {
+		teamEntry.Name = team.TeamName
+		teamEntry.Owner = team.TeamUser.String()
+		teamEntry.PictureUrl = picture.URL(team.TeamPicture, 0)
+		teamEntry.CreatedAt = timestamppb.New(team.CreatedAt)
+
+		// Add the members of the team
+		teamMembers, err := app.Models.TeamMembers.ListByOwner(ctx, team.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, teamMember := range teamMembers {
+			teamEntry.Members = append(teamEntry.Members, &teams.TeamMember{
+				Id:        teamMember.ID.String(),
+				User:      teamMember.TeamMemberUser.String(),
+				FirstName: teamMember.TeamMemberUserFirstName,
+				LastName:  teamMember.TeamMemberUserLastName,
+			})
+		}
+	}
+
+	// Send to gRPC - Go Team Indexing Service
+	ctx, cancel := context.WithTimeout(ctx, app.Config.GRPCTeamTimeout)
+	defer cancel()
+
+	_, err := app.TeamIndexing.WriteTeam(ctx, &teams.TeamRequest{
+		TeamEntry: teamEntry,
+		Operation: operation,
+	})
+
+	grpcIndexingCallsTotal.WithLabelValues(operation.String(), status.Code(err).String()).Inc()
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// dispatchTeamEvents delivers the team events of the outbox to the
+// Go Team Indexing Service until the context is cancelled
+func (app *Application) dispatchTeamEvents(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Outbox.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Deliver what is already due before stopping, within the shutdown
+			// timeout so an unavailable indexing service can't hold the shutdown
+			drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			app.deliverTeamEvents(drainCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			app.deliverTeamEvents(ctx)
+		}
+	}
+}
+
+func (app *Application) deliverTeamEvents(ctx context.Context) {
+	for {
+		// Claim a batch of the pending events
+		events, err := app.Models.TeamEvents.Claim(
+			ctx,
+			app.Config.Outbox.BatchSize,
+			app.Config.Outbox.MaxAttempts,
+			teamEventLease)
+		if err != nil {
+			app.Logger.PrintError(err, map[string]string{
+				"task": "team events",
+			})
+			return
+		}
+
+		for _, event := range events {
+			err = app.deliverTeamEvent(ctx, event)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task":     "team events",
+					"event_id": strconv.FormatInt(event.ID, 10),
+					"team_id":  event.TeamID.String(),
+					"attempt":  strconv.Itoa(event.Attempts + 1),
+				})
+
+				// Schedule the next attempt
+				retryAt := time.Now().Add(app.teamEventBackoff(event.Attempts))
+				err = app.Models.TeamEvents.MarkFailed(ctx, event, err, retryAt)
+				if err != nil {
+					app.Logger.PrintError(err, map[string]string{
+						"task": "team events",
+					})
+				}
+				continue
+			}
+
+			err = app.Models.TeamEvents.MarkDelivered(ctx, event)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task": "team events",
+				})
+			}
+		}
+
+		// Stop when the outbox has no more pending events
+		if len(events) < app.Config.Outbox.BatchSize {
+			return
+		}
+	}
+}
+
+func (app *Application) deliverTeamEvent(ctx context.Context, event *data.TeamEvent) error {
+	// A deleted team only needs its ID and version
+	if event.Operation == data.TeamEventDelete {
+		team := &data.Team{
+			ID:           event.TeamID,
+			IndexVersion: event.TeamVersion,
+		}
+
+		return app.gRPCTeamIndexing(ctx, teams.Operation_OPERATION_DELETE, team)
+	}
+
+	// Get the current state of the team
+	team, err := app.Models.Teams.GetByID(ctx, event.TeamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// Nothing left to index
+			return nil
+		default:
+			return err
+		}
+	}
+
+	// A newer event will index the team again
+	if team.IndexVersion > event.TeamVersion {
+		return nil
+	}
+
+	return app.gRPCTeamIndexing(ctx, teams.Operation_OPERATION_UPSERT, team)
+}
+
+// teamEventBackoff doubles the delay after every failed attempt
+func (app *Application) teamEventBackoff(attempts int) time.Duration {
+	backoff := app.Config.Outbox.Interval
+	for i := 0; i < attempts && backoff < teamEventMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > teamEventMaxBackoff {
+		backoff = teamEventMaxBackoff
+	}
+
+	return backoff
+}
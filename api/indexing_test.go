@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/data/mocks"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTeamIndexing stands in for the Go Team Indexing Service,
+// it fails the first writes and keeps the requests it accepts
+type testTeamIndexing struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	requests []*teams.TeamRequest
+}
+
+func (c *testTeamIndexing) WriteTeam(ctx context.Context, in *teams.TeamRequest, opts ...grpc.CallOption) (*teams.TeamResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	if c.failures > 0 {
+		c.failures--
+		return nil, status.Error(codes.Unavailable, "indexing service unavailable")
+	}
+
+	c.requests = append(c.requests, in)
+
+	return &teams.TeamResponse{}, nil
+}
+
+// testTeamEventModel is an outbox in memory, an event is claimed
+// again once its next attempt is due, like in the database
+type testTeamEventModel struct {
+	mu        sync.Mutex
+	events    []*data.TeamEvent
+	nextAt    map[int64]time.Time
+	delivered map[int64]bool
+	failures  int
+}
+
+func (m *testTeamEventModel) add(event *data.TeamEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nextAt == nil {
+		m.nextAt = make(map[int64]time.Time)
+		m.delivered = make(map[int64]bool)
+	}
+
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, event)
+}
+
+func (m *testTeamEventModel) Claim(ctx context.Context, limit int, maxAttempts int, lease time.Duration) ([]*data.TeamEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimed := []*data.TeamEvent{}
+
+	for _, event := range m.events {
+		if len(claimed) == limit {
+			break
+		}
+
+		if m.delivered[event.ID] || event.Attempts >= maxAttempts || m.nextAt[event.ID].After(time.Now()) {
+			continue
+		}
+
+		m.nextAt[event.ID] = time.Now().Add(lease)
+
+		claim := *event
+		claimed = append(claimed, &claim)
+	}
+
+	return claimed, nil
+}
+
+func (m *testTeamEventModel) MarkDelivered(ctx context.Context, event *data.TeamEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events[event.ID-1].Attempts++
+	m.delivered[event.ID] = true
+
+	return nil
+}
+
+func (m *testTeamEventModel) MarkFailed(ctx context.Context, event *data.TeamEvent, reason error, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events[event.ID-1].Attempts++
+	m.nextAt[event.ID] = retryAt
+	m.failures++
+
+	return nil
+}
+
+func (m *testTeamEventModel) isDelivered(id int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.delivered[id]
+}
+
+func testTeamEventsApplication(t *testing.T) (*Application, *testTeamEventModel, *testTeamIndexing) {
+	app := testApplication(t)
+	app.Config.Outbox.Interval = time.Millisecond
+	app.Config.Outbox.BatchSize = 10
+	app.Config.Outbox.MaxAttempts = 5
+	app.Config.GRPCTeamTimeout = time.Second
+
+	events := &testTeamEventModel{}
+	app.Models.TeamEvents = events
+
+	indexing := &testTeamIndexing{}
+	app.TeamIndexing = indexing
+
+	return app, events, indexing
+}
+
+func TestTeamEvents(t *testing.T) {
+	t.Run("Retry", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+		indexing.failures = 3
+
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 1, Operation: data.TeamEventUpsert})
+
+		// Deliver the due events until the indexing service accepts the event
+		deadline := time.Now().Add(5 * time.Second)
+		for !events.isDelivered(1) && time.Now().Before(deadline) {
+			app.deliverTeamEvents(context.Background())
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.True(t, events.isDelivered(1))
+		assert.Equal(t, 4, indexing.calls)
+		assert.Equal(t, 3, events.failures)
+		assert.Equal(t, 4, events.events[0].Attempts)
+		assert.Len(t, indexing.requests, 1)
+	})
+
+	t.Run("Max Attempts", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+		indexing.failures = 100
+
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 1, Operation: data.TeamEventUpsert})
+
+		// The event isn't claimed anymore once its attempts are spent
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			app.deliverTeamEvents(context.Background())
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.False(t, events.isDelivered(1))
+		assert.Equal(t, app.Config.Outbox.MaxAttempts, indexing.calls)
+		assert.Equal(t, app.Config.Outbox.MaxAttempts, events.events[0].Attempts)
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+		app.Config.Outbox.Interval = time.Minute
+		indexing.failures = 1
+
+		// The delay doubles after every failed attempt, up to the cap
+		assert.Equal(t, time.Minute, app.teamEventBackoff(0))
+		assert.Equal(t, 2*time.Minute, app.teamEventBackoff(1))
+		assert.Equal(t, 8*time.Minute, app.teamEventBackoff(3))
+		assert.Equal(t, teamEventMaxBackoff, app.teamEventBackoff(4))
+		assert.Equal(t, teamEventMaxBackoff, app.teamEventBackoff(1000))
+
+		// A failed event is scheduled after its backoff
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 1, Operation: data.TeamEventUpsert, Attempts: 6})
+
+		before := time.Now()
+		app.Config.Outbox.MaxAttempts = 10
+		app.deliverTeamEvents(context.Background())
+
+		assert.Equal(t, 1, events.failures)
+		assert.WithinDuration(t, before.Add(teamEventMaxBackoff), events.nextAt[1], time.Second)
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+		app.Config.Outbox.Interval = time.Hour
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			app.dispatchTeamEvents(ctx)
+			close(done)
+		}()
+
+		// An event recorded before the shutdown is delivered before the dispatcher stops
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 1, Operation: data.TeamEventDelete})
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout + time.Second):
+			t.Fatal("the dispatcher didn't stop within the shutdown timeout")
+		}
+
+		assert.True(t, events.isDelivered(1))
+		assert.Len(t, indexing.requests, 1)
+	})
+}
+
+func TestTeamIndexing(t *testing.T) {
+	t.Run("Document", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 1, Operation: data.TeamEventUpsert})
+		app.deliverTeamEvents(context.Background())
+
+		if !assert.Len(t, indexing.requests, 1) {
+			return
+		}
+
+		// The document holds the current team and its members
+		request := indexing.requests[0]
+		assert.Equal(t, teams.Operation_OPERATION_UPSERT, request.Operation)
+
+		team, _ := app.Models.Teams.GetByID(context.Background(), mocks.MockFirstUUID())
+		document := request.TeamEntry
+		assert.Equal(t, team.ID.String(), document.Id)
+		assert.Equal(t, int64(team.IndexVersion), document.Version)
+		assert.Equal(t, team.TeamName, document.Name)
+		assert.Equal(t, team.TeamUser.String(), document.Owner)
+		assert.Equal(t, picture.BaseURL+team.TeamPicture, document.PictureUrl)
+		assert.NotNil(t, document.CreatedAt)
+
+		if assert.Len(t, document.Members, 1) {
+			assert.Equal(t, mocks.MockFirstUUID().String(), document.Members[0].Id)
+			assert.Equal(t, mocks.MockSecondUUID().String(), document.Members[0].User)
+			assert.Equal(t, "Nina", document.Members[0].FirstName)
+			assert.Equal(t, "Doe", document.Members[0].LastName)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 4, Operation: data.TeamEventDelete})
+		app.deliverTeamEvents(context.Background())
+
+		if !assert.Len(t, indexing.requests, 1) {
+			return
+		}
+
+		// A deleted team is only identified by its ID and version
+		request := indexing.requests[0]
+		assert.Equal(t, teams.Operation_OPERATION_DELETE, request.Operation)
+		assert.Equal(t, mocks.MockFirstUUID().String(), request.TeamEntry.Id)
+		assert.Equal(t, int64(4), request.TeamEntry.Version)
+		assert.Empty(t, request.TeamEntry.Name)
+		assert.Empty(t, request.TeamEntry.Members)
+	})
+
+	t.Run("Stale Event", func(t *testing.T) {
+		app, events, indexing := testTeamEventsApplication(t)
+
+		// The team changed again since the event was recorded, and a team
+		// which doesn't exist anymore has nothing to index
+		events.add(&data.TeamEvent{TeamID: mocks.MockFirstUUID(), TeamVersion: 0, Operation: data.TeamEventUpsert})
+		events.add(&data.TeamEvent{TeamID: mocks.MockSecondUUID(), TeamVersion: 1, Operation: data.TeamEventUpsert})
+		app.deliverTeamEvents(context.Background())
+
+		assert.True(t, events.isDelivered(1))
+		assert.True(t, events.isDelivered(2))
+		assert.Equal(t, 0, indexing.calls)
+	})
+}
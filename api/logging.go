@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+	"github.com/google/uuid"
+)
+
+// requestLogger returns a child logger bound to the request ID,
+// the current user and the team of the request
+func (app *Application) requestLogger(r *http.Request) *jsonlog.Logger {
+	info := app.contextGetRequestInfo(r)
+	if info == nil {
+		return app.Logger
+	}
+
+	fields := []jsonlog.Field{jsonlog.String("request_id", info.ID)}
+
+	if info.User != nil && !info.User.IsAnonymous() {
+		fields = append(fields, jsonlog.Stringer("user_id", info.User.ID))
+	}
+
+	if info.Team != uuid.Nil {
+		fields = append(fields, jsonlog.Stringer("team_id", info.Team))
+	}
+
+	return app.Logger.With(fields...)
+}
+
+func (app *Application) getLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"level": app.Logger.Level()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	// Set input
+	var input struct {
+		Level string `json:"level"`
+	}
+
+	// Read JSON from the request
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the level
+	level, err := jsonlog.ParseLevel(input.Level)
+
+	v := validator.New()
+	v.Check(err == nil, "level", "must be debug, info, warn, error, fatal or off")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The level applies at once to every logger of the service
+	app.Logger.SetLevel(level)
+
+	app.requestLogger(r).Warn("log level changed", jsonlog.Stringer("level", level))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"level": level}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"expvar"
+	"net/http"
+	"strconv"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The expvar counters of the requests, published at /service/teams/debug/vars
+var (
+	totalRequestsReceived           = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
+	totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+)
+
+// registry holds the Prometheus metrics, published at /metrics
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "teams_http_requests_total",
+		Help: "Number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "teams_http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcIndexingCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "teams_grpc_indexing_calls_total",
+		Help: "Number of calls to the Go Team Indexing Service by operation and gRPC status code.",
+	}, []string{"operation", "code"})
+
+	rateLimitRejectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "teams_rate_limit_rejections_total",
+		Help: "Number of requests rejected by the rate limiter.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		grpcIndexingCallsTotal,
+		rateLimitRejectionsTotal,
+	)
+}
+
+// registerDBStats adds the statistics of the connection pool to the metrics
+func registerDBStats(db *sql.DB) {
+	if db != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(db, "teams"))
+	}
+}
+
+// metricsHandler serves the Prometheus metrics
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// The route label of a request which doesn't match any route,
+// its path isn't used so the number of labels stays bounded
+const unmatchedRoute = "unmatched"
+
+// instrumentedRouter registers the handlers of httprouter with the template
+// of their path, which becomes the route label of the metrics and the name
+// of the server span
+type instrumentedRouter struct {
+	*httprouter.Router
+}
+
+func newInstrumentedRouter() instrumentedRouter {
+	return instrumentedRouter{httprouter.New()}
+}
+
+func (ir instrumentedRouter) Handler(method, path string, handler http.Handler) {
+	ir.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+			info.Route = path
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(method + " " + path)
+		span.SetAttributes(semconv.HTTPRouteKey.String(path))
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (ir instrumentedRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	ir.Handler(method, path, handler)
+}
+
+func (app *Application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		totalRequestsReceived.Add(1)
+
+		metrics := httpsnoop.CaptureMetrics(next, w, r)
+
+		totalResponsesSent.Add(1)
+
+		totalProcessingTimeMicroseconds.Add(metrics.Duration.Microseconds())
+
+		totalResponsesSentByStatus.Add(strconv.Itoa(metrics.Code), 1)
+
+		// The router sets the route of the request once it's matched
+		route := unmatchedRoute
+		if info := app.contextGetRequestInfo(r); info != nil {
+			route = info.Route
+		}
+
+		status := strconv.Itoa(metrics.Code)
+		httpRequestsTotal.WithLabelValues(r.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route, status).Observe(metrics.Duration.Seconds())
+	})
+}
@@ -2,15 +2,15 @@ package api
 
 import (
 	"errors"
-	"expvar"
 	"fmt"
 	"net/http"
-	"strconv"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
 	"github.com/google/uuid"
 
 	"github.com/felixge/httpsnoop"
@@ -25,6 +25,48 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// requestIDRX matches the request IDs accepted from the clients,
+// any other value would be written as it is into the logs
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID accepts or generates the X-Request-ID of a request, and echoes it in the response
+func (app *Application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		r = app.contextSetRequestInfo(r, &requestInfo{ID: id, Route: unmatchedRoute})
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequest writes the access log line of a request once it's handled
+func (app *Application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics := httpsnoop.CaptureMetrics(next, w, r)
+
+		route := unmatchedRoute
+		if info := app.contextGetRequestInfo(r); info != nil {
+			route = info.Route
+		}
+
+		app.requestLogger(r).Info("request",
+			jsonlog.String("request_method", r.Method),
+			jsonlog.String("request_url", r.URL.String()),
+			jsonlog.String("route", route),
+			jsonlog.Int("status", metrics.Code),
+			jsonlog.Int64("bytes", metrics.Written),
+			jsonlog.Duration("duration_ms", metrics.Duration),
+			jsonlog.String("remote_ip", realip.FromRequest(r)),
+		)
+	})
+}
+
 func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -81,6 +123,7 @@ func (app *Application) rateLimit(next http.Handler) http.Handler {
 
 			if !clients[ip].limiter.Allow() {
 				mu.Unlock()
+				rateLimitRejectionsTotal.Inc()
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
@@ -104,11 +147,12 @@ func (app *Application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.Config.Cors.TrustedOrigins {
 				if origin == app.Config.Cors.TrustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed, X-Request-ID")
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, If-Match, X-Request-ID")
 
 						w.WriteHeader(http.StatusOK)
 						return
@@ -123,27 +167,6 @@ func (app *Application) enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-func (app *Application) metrics(next http.Handler) http.Handler {
-	totalRequestsReceived := expvar.NewInt("profile_total_requests_received")
-	totalResponsesSent := expvar.NewInt("profile_total_responses_sent")
-	totalProcessingTimeMicroseconds := expvar.NewInt("profile_total_processing_time_μs")
-
-	totalResponsesSentByStatus := expvar.NewMap("profile_total_responses_sent_by_status")
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		totalRequestsReceived.Add(1)
-
-		metrics := httpsnoop.CaptureMetrics(next, w, r)
-
-		totalResponsesSent.Add(1)
-
-		totalProcessingTimeMicroseconds.Add(metrics.Duration.Microseconds())
-
-		totalResponsesSentByStatus.Add(strconv.Itoa(metrics.Code), 1)
-	})
-}
-
 func (app *Application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get an Authorization header from HTTP request
@@ -189,7 +212,7 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Get a user by ID from the Claim token
-		user, err := app.Models.Users.GetByID(claims.ID)
+		user, err := app.Models.Users.GetByID(r.Context(), claims.ID)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -222,3 +245,21 @@ func (app *Application) requireAuthenticated(next http.HandlerFunc) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (app *Application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		// Only the configured admin users can continue
+		for _, id := range app.Config.Admin.Users {
+			if id == user.ID {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		app.notPermittedResponse(w, r)
+	}
+
+	return app.requireAuthenticated(fn)
+}
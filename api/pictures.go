@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+)
+
+// storeTeamPicture stores an uploaded picture and its thumbnails, and returns
+// the name of the picture. The picture is processed in memory, the caller
+// limits the size of the file.
+func (app *Application) storeTeamPicture(ctx context.Context, file io.Reader) (string, error) {
+	pic, err := picture.Process(file, app.Config.Picture.MaxWidth, app.Config.Picture.MaxHeight)
+	if err != nil {
+		return "", err
+	}
+
+	// The name changes with the content, so a
+	// new picture is never served from a cache
+	name := pic.Name()
+
+	// Store the thumbnails first, so they exist
+	// as soon as the picture can be referenced
+	for _, variant := range pic.Variants {
+		err = app.Storage.Put(ctx, picture.VariantName(name, variant.Size), bytes.NewReader(variant.Data), int64(len(variant.Data)), pic.ContentType)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = app.Storage.Put(ctx, name, bytes.NewReader(pic.Original), int64(len(pic.Original)), pic.ContentType)
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// deleteTeamPicture deletes a picture and its thumbnails, unless another
+// team still refers to the same picture, even a team which can be restored
+func (app *Application) deleteTeamPicture(ctx context.Context, name string) error {
+	referenced, err := app.Models.Teams.PictureReferenced(ctx, name, true)
+	if err != nil {
+		return err
+	}
+
+	if referenced {
+		return nil
+	}
+
+	for _, size := range append([]int{0}, picture.Sizes...) {
+		err := app.Storage.Delete(ctx, picture.VariantName(name, size))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discardTeamPicture deletes a stored picture of a request which failed,
+// the request already has a response so a failure is only logged
+func (app *Application) discardTeamPicture(r *http.Request, name string) {
+	if name == "" {
+		return
+	}
+
+	err := app.deleteTeamPicture(r.Context(), name)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// maxTeamNameBytes is the maximum size of the team name field,
+// the longest name of the team_name column in UTF-8
+const maxTeamNameBytes = data.TeamNameMaxLength * utf8.UTFMax
+
+// teamForm holds the fields of the form of a team,
+// the picture is the name of the stored picture
+type teamForm struct {
+	TeamName    string
+	TeamPicture string
+}
+
+// readTeamForm reads the form of a team. The parts of a multipart form are read
+// one after the other instead of parsing the whole form at once, and the body is
+// limited to the maximum upload size. The picture part is still read in memory,
+// to be decoded and re-encoded before it is stored. A picture stored before
+// a later part fails is deleted.
+func (app *Application) readTeamForm(w http.ResponseWriter, r *http.Request) (_ *teamForm, ok bool) {
+	maxBytes := app.Config.Picture.MaxUploadSize
+
+	// Refuse a body which is too large before reading it
+	if r.ContentLength > maxBytes {
+		app.requestTooLargeResponse(w, r, maxBytes)
+		return nil, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	form := &teamForm{}
+
+	defer func() {
+		if !ok {
+			app.discardTeamPicture(r, form.TeamPicture)
+		}
+	}()
+
+	// An empty body is an empty form
+	if r.ContentLength == 0 {
+		return form, true
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		// A form without a picture doesn't have to be multipart
+		if errors.Is(err, http.ErrNotMultipart) {
+			err = r.ParseForm()
+			if err == nil {
+				form.TeamName = r.PostForm.Get("team_name")
+				return form, true
+			}
+		}
+
+		app.teamFormErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			app.teamFormErrorResponse(w, r, err)
+			return nil, false
+		}
+
+		switch {
+		case part.FormName() == "team_name" && part.FileName() == "":
+			value, err := io.ReadAll(io.LimitReader(part, maxTeamNameBytes+1))
+			if err != nil {
+				app.teamFormErrorResponse(w, r, err)
+				return nil, false
+			}
+
+			if len(value) > maxTeamNameBytes {
+				v := validator.New()
+				v.AddError("team_name", fmt.Sprintf("must not be more than %d characters long", data.TeamNameMaxLength))
+				app.failedValidationResponse(w, r, v.Errors)
+				return nil, false
+			}
+
+			form.TeamName = string(value)
+
+		case part.FormName() == "team_picture" && part.FileName() != "" && form.TeamPicture == "":
+			// Store the picture and its thumbnails
+			form.TeamPicture, err = app.storeTeamPicture(r.Context(), part)
+			if err != nil {
+				app.teamPictureErrorResponse(w, r, err)
+				return nil, false
+			}
+		}
+
+		part.Close()
+	}
+
+	return form, true
+}
+
+// teamFormErrorResponse sends the response of a team form which can't be read
+func (app *Application) teamFormErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		app.requestTooLargeResponse(w, r, maxBytesError.Limit)
+	default:
+		app.badRequestResponse(w, r, err)
+	}
+}
+
+// teamPictureErrorResponse sends the response of a picture which can't be stored
+func (app *Application) teamPictureErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError), errors.Is(err, io.ErrUnexpectedEOF):
+		app.teamFormErrorResponse(w, r, err)
+	case errors.Is(err, picture.ErrUnsupported):
+		app.badRequestResponse(w, r, errors.New("please upload a JPEG or PNG image"))
+	case errors.Is(err, picture.ErrTooLarge):
+		v := validator.New()
+		v.AddError("team_picture", fmt.Sprintf("must be at most %dx%d pixels", app.Config.Picture.MaxWidth, app.Config.Picture.MaxHeight))
+		app.failedValidationResponse(w, r, v.Errors)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+)
+
+type teamAction string
+
+const (
+	actionReadTeam      teamAction = "read_team"
+	actionUpdateTeam    teamAction = "update_team"
+	actionDeleteTeam    teamAction = "delete_team"
+	actionListMembers   teamAction = "list_members"
+	actionManageMembers teamAction = "manage_members"
+	actionManageAdmins  teamAction = "manage_admins"
+	actionChangeRoles   teamAction = "change_roles"
+	actionTransferTeam  teamAction = "transfer_team"
+)
+
+// teamPermissions lists the actions allowed for every role of a team
+var teamPermissions = map[string][]teamAction{
+	data.RoleOwner: {
+		actionReadTeam,
+		actionUpdateTeam,
+		actionDeleteTeam,
+		actionListMembers,
+		actionManageMembers,
+		actionManageAdmins,
+		actionChangeRoles,
+		actionTransferTeam,
+	},
+	data.RoleAdmin: {
+		actionReadTeam,
+		actionUpdateTeam,
+		actionListMembers,
+		actionManageMembers,
+	},
+	data.RoleMember: {
+		actionReadTeam,
+		actionListMembers,
+	},
+	data.RoleViewer: {
+		actionReadTeam,
+	},
+}
+
+// teamRole returns the role of the user in the team,
+// or an empty string if the user doesn't belong to the team
+func (app *Application) teamRole(ctx context.Context, user *data.User, team *data.Team) (string, error) {
+	if team.TeamUser == user.ID {
+		return data.RoleOwner, nil
+	}
+
+	teamMember, err := app.Models.TeamMembers.GetByTeamAndUser(ctx, team.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", nil
+		default:
+			return "", err
+		}
+	}
+
+	return teamMember.TeamMemberRole, nil
+}
+
+// can reports whether the user is allowed to do the action on the team
+func (app *Application) can(ctx context.Context, user *data.User, action teamAction, team *data.Team) (bool, error) {
+	role, err := app.teamRole(ctx, user, team)
+	if err != nil {
+		return false, err
+	}
+
+	for _, allowed := range teamPermissions[role] {
+		if allowed == action {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// authorizeTeam sends the error response and returns false when
+// the current user isn't allowed to do the action on the team
+func (app *Application) authorizeTeam(w http.ResponseWriter, r *http.Request, action teamAction, team *data.Team) bool {
+	user := app.contextGetUser(r)
+
+	// The logs of the request are about the team
+	if info := app.contextGetRequestInfo(r); info != nil {
+		info.Team = team.ID
+	}
+
+	allowed, err := app.can(r.Context(), user, action, team)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// purgeTeams periodically hard-deletes the teams which have been
+// soft deleted for longer than the retention window
+func (app *Application) purgeTeams(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Purge.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeTeamsBatch(ctx)
+		}
+	}
+}
+
+func (app *Application) purgeTeamsBatch(ctx context.Context) {
+	deletedBefore := time.Now().Add(-app.Config.Purge.Retention)
+
+	purgeable, err := app.Models.Teams.ListPurgeable(ctx, deletedBefore, app.Config.Purge.BatchSize)
+	if err != nil {
+		app.Logger.PrintError(err, map[string]string{
+			"task": "purge teams",
+		})
+		return
+	}
+
+	for _, team := range purgeable {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err = app.Models.Teams.Purge(ctx, team)
+		if err != nil {
+			app.Logger.PrintError(err, map[string]string{
+				"task":    "purge teams",
+				"team_id": team.ID.String(),
+			})
+			continue
+		}
+
+		// Remove the picture of the purged team
+		if team.TeamPicture != "" {
+			err = app.deleteTeamPicture(ctx, team.TeamPicture)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task":    "purge teams",
+					"team_id": team.ID.String(),
+				})
+			}
+		}
+	}
+}
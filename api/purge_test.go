@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/data/mocks"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPurgeableTeamModel lists the given teams as purgeable, a purge
+// records the delete event in the outbox like the database does
+type testPurgeableTeamModel struct {
+	mocks.TeamModel
+	teams         []*data.Team
+	deletedBefore time.Time
+	limit         int
+	events        *testTeamEventModel
+	purged        []uuid.UUID
+}
+
+func (m *testPurgeableTeamModel) ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]*data.Team, error) {
+	m.deletedBefore = deletedBefore
+	m.limit = limit
+
+	return m.teams, nil
+}
+
+func (m *testPurgeableTeamModel) Purge(ctx context.Context, team *data.Team) error {
+	m.purged = append(m.purged, team.ID)
+
+	team.IndexVersion++
+	m.events.add(&data.TeamEvent{TeamID: team.ID, TeamVersion: team.IndexVersion, Operation: data.TeamEventDelete})
+
+	return nil
+}
+
+func TestPurgeTeams(t *testing.T) {
+	app, events, indexing := testTeamEventsApplication(t)
+	app.Config.Purge.Retention = 24 * time.Hour
+	app.Config.Purge.BatchSize = 10
+
+	ctx := context.Background()
+
+	// Store a picture which only the purged team refers to
+	file, err := os.Open("./test/images/team.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	name, err := app.storeTeamPicture(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := &testPurgeableTeamModel{
+		teams: []*data.Team{
+			{ID: uuid.New(), TeamPicture: name, IsDeleted: true, IndexVersion: 2},
+			{ID: uuid.New(), TeamPicture: mocks.MockFirstUUID().String() + ".jpg", IsDeleted: true, IndexVersion: 3},
+		},
+		events: events,
+	}
+	app.Models.Teams = model
+
+	before := time.Now()
+	app.purgeTeamsBatch(ctx)
+
+	// The teams deleted before the retention window are purged
+	assert.WithinDuration(t, before.Add(-app.Config.Purge.Retention), model.deletedBefore, time.Second)
+	assert.Equal(t, app.Config.Purge.BatchSize, model.limit)
+	assert.Equal(t, []uuid.UUID{model.teams[0].ID, model.teams[1].ID}, model.purged)
+
+	// The picture of the purged team is deleted with its thumbnails
+	for _, size := range append([]int{0}, picture.Sizes...) {
+		_, err = app.Storage.Get(ctx, picture.VariantName(name, size))
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+
+	// A picture another team still refers to is kept
+	blob, err := app.Storage.Get(ctx, mocks.MockFirstUUID().String()+".jpg")
+	if assert.Nil(t, err) {
+		blob.Close()
+	}
+
+	// The purged teams are deleted from the index
+	app.deliverTeamEvents(ctx)
+
+	if assert.Len(t, indexing.requests, 2) {
+		for i, request := range indexing.requests {
+			assert.Equal(t, teams.Operation_OPERATION_DELETE, request.Operation)
+			assert.Equal(t, model.teams[i].ID.String(), request.TeamEntry.Id)
+			assert.Equal(t, int64(model.teams[i].IndexVersion), request.TeamEntry.Version)
+		}
+	}
+}
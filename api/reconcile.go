@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+)
+
+// reconcileTeams periodically re-indexes the teams which are not flagged
+// as indexed, so the search catches up even when events were lost
+func (app *Application) reconcileTeams(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Reconciler.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.reconcileTeamsBatch(ctx)
+		}
+	}
+}
+
+func (app *Application) reconcileTeamsBatch(ctx context.Context) {
+	// Get a batch of the unindexed teams
+	unindexed, err := app.Models.Teams.ListUnindexed(ctx, app.Config.Reconciler.BatchSize)
+	if err != nil {
+		app.Logger.PrintError(err, map[string]string{
+			"task": "reconcile teams",
+		})
+		return
+	}
+
+	// Index the teams with a limited number of workers
+	concurrency := app.Config.Reconciler.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, team := range unindexed {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(team *data.Team) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			operation := teams.Operation_OPERATION_UPSERT
+			if team.IsDeleted {
+				operation = teams.Operation_OPERATION_DELETE
+			}
+
+			err := app.gRPCTeamIndexing(ctx, operation, team)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task":    "reconcile teams",
+					"team_id": team.ID.String(),
+				})
+				return
+			}
+
+			// Flag the team once the indexing service acknowledged it
+			err = app.Models.Teams.MarkIndexed(ctx, team)
+			if err != nil {
+				app.Logger.PrintError(err, map[string]string{
+					"task":    "reconcile teams",
+					"team_id": team.ID.String(),
+				})
+			}
+		}(team)
+	}
+
+	wg.Wait()
+}
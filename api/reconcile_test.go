@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/data/mocks"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testUnindexedTeamModel lists the given teams as unindexed,
+// and keeps the teams flagged as indexed
+type testUnindexedTeamModel struct {
+	mocks.TeamModel
+	mu      sync.Mutex
+	teams   []*data.Team
+	limit   int
+	indexed []uuid.UUID
+}
+
+func (m *testUnindexedTeamModel) ListUnindexed(ctx context.Context, limit int) ([]*data.Team, error) {
+	m.limit = limit
+
+	return m.teams[:min(limit, len(m.teams))], nil
+}
+
+func (m *testUnindexedTeamModel) MarkIndexed(ctx context.Context, team *data.Team) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.indexed = append(m.indexed, team.ID)
+
+	return nil
+}
+
+func TestReconcileTeams(t *testing.T) {
+	app := testApplication(t)
+	app.Config.Reconciler.BatchSize = 3
+	app.Config.Reconciler.Concurrency = 1
+	app.Config.GRPCTeamTimeout = time.Second
+
+	// The indexing service fails the first team
+	indexing := &testTeamIndexing{failures: 1}
+	app.TeamIndexing = indexing
+
+	model := &testUnindexedTeamModel{}
+	for i := 0; i < 4; i++ {
+		model.teams = append(model.teams, &data.Team{
+			ID:           uuid.New(),
+			TeamUser:     mocks.MockFirstUUID(),
+			TeamName:     "Doe's Team",
+			IndexVersion: 2,
+			IsDeleted:    i == 2,
+		})
+	}
+	app.Models.Teams = model
+
+	app.reconcileTeamsBatch(context.Background())
+
+	// A batch is reconciled per run
+	assert.Equal(t, 3, model.limit)
+	assert.Equal(t, 3, indexing.calls)
+
+	// A deleted team is removed from the index
+	if assert.Len(t, indexing.requests, 2) {
+		assert.Equal(t, teams.Operation_OPERATION_UPSERT, indexing.requests[0].Operation)
+		assert.Equal(t, model.teams[1].ID.String(), indexing.requests[0].TeamEntry.Id)
+		assert.Equal(t, teams.Operation_OPERATION_DELETE, indexing.requests[1].Operation)
+		assert.Equal(t, model.teams[2].ID.String(), indexing.requests[1].TeamEntry.Id)
+	}
+
+	// Only the acknowledged teams are flagged, the failed team is reconciled by the next run
+	assert.Equal(t, []uuid.UUID{model.teams[1].ID, model.teams[2].ID}, model.indexed)
+}
@@ -3,27 +3,100 @@ package api
 import (
 	"expvar"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// routerMethods are the methods the routers are registered with
+var routerMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
 func (app *Application) Routes() http.Handler {
-	router := httprouter.New()
+	// Routes of a single team (/service/teams/:id/...) have their own router,
+	// because httprouter doesn't allow the :id wildcard next to the static
+	// segments (me, members, pictures, ...) of the main router
+	teamRouter := newInstrumentedRouter()
+
+	teamRouter.NotFound = http.HandlerFunc(app.notFoundResponse)
+	teamRouter.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	teamRouter.HandlerFunc(http.MethodGet, "/service/teams/:id", app.requireAuthenticated(app.getTeamHandler))
+	teamRouter.HandlerFunc(http.MethodPatch, "/service/teams/:id", app.requireAuthenticated(app.patchTeamHandler))
+	teamRouter.HandlerFunc(http.MethodDelete, "/service/teams/:id", app.requireAuthenticated(app.deleteTeamHandler))
+	teamRouter.HandlerFunc(http.MethodDelete, "/service/teams/:id/picture", app.requireAuthenticated(app.deleteTeamPictureHandler))
+	teamRouter.HandlerFunc(http.MethodPost, "/service/teams/:id/restore", app.requireAdmin(app.restoreTeamHandler))
+	teamRouter.HandlerFunc(http.MethodPost, "/service/teams/:id/invitations", app.requireAuthenticated(app.createTeamInvitationHandler))
+	teamRouter.HandlerFunc(http.MethodDelete, "/service/teams/:id/members/me", app.requireAuthenticated(app.leaveTeamHandler))
+	teamRouter.HandlerFunc(http.MethodPost, "/service/teams/:id/transfer", app.requireAuthenticated(app.createTeamTransferHandler))
+	teamRouter.HandlerFunc(http.MethodGet, "/service/teams/:id/transfer", app.requireAuthenticated(app.getTeamTransferHandler))
+	teamRouter.HandlerFunc(http.MethodDelete, "/service/teams/:id/transfer", app.requireAuthenticated(app.cancelTeamTransferHandler))
+	teamRouter.HandlerFunc(http.MethodPost, "/service/teams/:id/transfer/accept", app.requireAuthenticated(app.acceptTeamTransferHandler))
 
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router := newInstrumentedRouter()
+
+	// Requests not matching the main router fall through to the team router,
+	// unless the main router has the path for other methods
+	router.HandleMethodNotAllowed = false
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow := allowedMethods(router.Router, r.URL.Path); allow != "" {
+			w.Header().Set("Allow", allow)
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+
+		teamRouter.ServeHTTP(w, r)
+	})
 
 	router.HandlerFunc(http.MethodGet, "/service/teams/health", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/service/teams", app.requireAuthenticated(app.createTeamHandler))
+	router.HandlerFunc(http.MethodGet, "/service/teams/health/live", app.livenessHandler)
+	router.HandlerFunc(http.MethodGet, "/service/teams/health/ready", app.readinessHandler)
+	router.HandlerFunc(http.MethodPost, "/service/teams", app.requireAuthenticated(app.idempotent(app.createTeamHandler)))
+	router.HandlerFunc(http.MethodGet, "/service/teams", app.requireAuthenticated(app.listTeamsHandler))
 	router.HandlerFunc(http.MethodGet, "/service/teams/me", app.requireAuthenticated(app.getOwnTeamHandler))
-	router.HandlerFunc(http.MethodPatch, "/service/teams/:id", app.requireAuthenticated(app.patchTeamHandler))
 	router.HandlerFunc(http.MethodGet, "/service/teams/pictures/:file", app.getProfilePictureHandler)
-	router.HandlerFunc(http.MethodPost, "/service/teams/members", app.requireAuthenticated(app.createTeamMemberHandler))
+	router.HandlerFunc(http.MethodPost, "/service/teams/members", app.requireAuthenticated(app.idempotent(app.createTeamMemberHandler)))
 	router.HandlerFunc(http.MethodGet, "/service/teams/members", app.requireAuthenticated(app.listTeamMembersByOwnerHandler))
 	router.HandlerFunc(http.MethodDelete, "/service/teams/members/:id", app.requireAuthenticated(app.deleteTeamMemberHandler))
 	router.HandlerFunc(http.MethodGet, "/service/teams/members/:id", app.requireAuthenticated(app.getTeamMemberHandler))
+	router.HandlerFunc(http.MethodPatch, "/service/teams/members/:id", app.requireAuthenticated(app.patchTeamMemberRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/service/teams/memberships/me", app.requireAuthenticated(app.listOwnTeamMembershipsHandler))
+	router.HandlerFunc(http.MethodGet, "/service/teams/invitations/me", app.requireAuthenticated(app.listOwnTeamInvitationsHandler))
+	router.HandlerFunc(http.MethodPost, "/service/teams/invitations/accept", app.requireAuthenticated(app.acceptTeamInvitationHandler))
+	router.HandlerFunc(http.MethodPost, "/service/teams/invitations/decline", app.requireAuthenticated(app.declineTeamInvitationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/service/teams/admin/log-level", app.requireAdmin(app.getLogLevelHandler))
+	router.HandlerFunc(http.MethodPut, "/service/teams/admin/log-level", app.requireAdmin(app.updateLogLevelHandler))
 
 	router.Handler(http.MethodGet, "/service/teams/debug/vars", expvar.Handler())
+	router.Handler(http.MethodGet, "/metrics", metricsHandler())
+
+	return app.tracing(app.requestID(app.metrics(app.logRequest(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))))))
+}
+
+// allowedMethods returns the Allow header of a path of the router,
+// like httprouter does, or an empty string if no method has the path
+func allowedMethods(router *httprouter.Router, path string) string {
+	var allowed []string
+
+	for _, method := range routerMethods {
+		if handle, _, _ := router.Lookup(method, path); handle != nil {
+			allowed = append(allowed, method)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return ""
+	}
+
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
 
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	return strings.Join(allowed, ", ")
 }
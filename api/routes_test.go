@@ -1,12 +1,33 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/e-inwork-com/go-team-service/internal/data"
 	"github.com/e-inwork-com/go-team-service/internal/data/mocks"
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/mailer"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestRoutes(t *testing.T) {
@@ -38,6 +59,24 @@ func TestRoutes(t *testing.T) {
 			body:         tBodyTeam,
 			expectedCode: http.StatusCreated,
 		},
+		{
+			name:         "Create Team Name Too Long",
+			method:       "POST",
+			urlPath:      "/service/teams",
+			contentType:  "application/x-www-form-urlencoded",
+			token:        firstToken,
+			body:         strings.NewReader("team_name=" + strings.Repeat("é", 101)),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Create Team Control Characters",
+			method:       "POST",
+			urlPath:      "/service/teams",
+			contentType:  "application/x-www-form-urlencoded",
+			token:        firstToken,
+			body:         strings.NewReader("team_name=Doe%0D%0ABcc%3A+eve%40doe.com"),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
 		{
 			name:         "Get Team",
 			method:       "GET",
@@ -47,6 +86,15 @@ func TestRoutes(t *testing.T) {
 			body:         nil,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:         "List Teams",
+			method:       "GET",
+			urlPath:      "/service/teams",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
 		{
 			name:         "Get Team Picture",
 			method:       "GET",
@@ -56,6 +104,87 @@ func TestRoutes(t *testing.T) {
 			body:         nil,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:         "Get Team Picture Unreferenced",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/" + strings.Repeat("a", 64) + ".jpg",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Invalid Name",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/team.jpg",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Encoded Traversal",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/..%2f..%2fetc%2fpasswd",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Encoded Dot Traversal",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/%2e%2e%2f%2e%2e%2fetc%2fpasswd",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Double Encoded Traversal",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/..%252f..%252fetc%252fpasswd",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Backslash Traversal",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/..%5c..%5cetc%5cpasswd",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Null Byte",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/" + mocks.MockFirstUUID().String() + ".jpg%00.png",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Get Team Picture Thumbnail",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/" + mocks.MockFirstUUID().String() + ".jpg?size=256",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Get Team Picture Invalid Size",
+			method:       "GET",
+			urlPath:      "/service/teams/pictures/" + mocks.MockFirstUUID().String() + ".jpg?size=100",
+			contentType:  "",
+			token:        "",
+			body:         nil,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
 		{
 			name:         "Patch Team",
 			method:       "PATCH",
@@ -65,6 +194,15 @@ func TestRoutes(t *testing.T) {
 			body:         tBodyTeam,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:         "Patch Team Name Too Long",
+			method:       "PATCH",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String(),
+			contentType:  "application/x-www-form-urlencoded",
+			token:        firstToken,
+			body:         strings.NewReader("team_name=" + strings.Repeat("a", 101)),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
 		{
 			name:         "Patch Team Forbidden",
 			method:       "PATCH",
@@ -74,6 +212,24 @@ func TestRoutes(t *testing.T) {
 			body:         tBodyTeam,
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			name:         "Delete Team Picture",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/picture",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Delete Team Picture Forbidden",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/picture",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusForbidden,
+		},
 		{
 			name:         "Create Team Member",
 			method:       "POST",
@@ -83,6 +239,15 @@ func TestRoutes(t *testing.T) {
 			body:         tJSONTeamMember,
 			expectedCode: http.StatusCreated,
 		},
+		{
+			name:         "Create Team Member Conflict",
+			method:       "POST",
+			urlPath:      "/service/teams/members",
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(fmt.Sprintf(`{"team_member_team": "%v", "team_member_user": "%v"}`, mocks.MockFirstUUID(), mocks.MockFirstUUID())),
+			expectedCode: http.StatusConflict,
+		},
 		{
 			name:         "Get Team Member",
 			method:       "GET",
@@ -101,6 +266,105 @@ func TestRoutes(t *testing.T) {
 			body:         nil,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:         "Get List Team Members Filtered",
+			method:       "GET",
+			urlPath:      "/service/teams/members?page=1&page_size=10&sort=-name&name=nina&team_member_role=member,viewer",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Get List Team Members Invalid Sort",
+			method:       "GET",
+			urlPath:      "/service/teams/members?sort=team_member_user",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Get List Team Members Invalid Page Size",
+			method:       "GET",
+			urlPath:      "/service/teams/members?page_size=1000",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Get List Team Members Invalid Role",
+			method:       "GET",
+			urlPath:      "/service/teams/members?team_member_role=owner",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Get List Team Members Invalid Cursor",
+			method:       "GET",
+			urlPath:      "/service/teams/members?cursor=abc",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Get List Team Members By Team",
+			method:       "GET",
+			urlPath:      "/service/teams/members?team_member_team=" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Get List Team Members By Team As Member",
+			method:       "GET",
+			urlPath:      "/service/teams/members?team_member_team=" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Patch Team Member Role",
+			method:       "PATCH",
+			urlPath:      "/service/teams/members/" + mocks.MockFirstUUID().String(),
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(`{"team_member_role": "admin"}`),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Patch Team Member Role Invalid",
+			method:       "PATCH",
+			urlPath:      "/service/teams/members/" + mocks.MockFirstUUID().String(),
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(`{"team_member_role": "owner"}`),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Patch Team Member Role Forbidden",
+			method:       "PATCH",
+			urlPath:      "/service/teams/members/" + mocks.MockFirstUUID().String(),
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(`{"team_member_role": "admin"}`),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Delete Team Members Forbidden",
+			method:       "DELETE",
+			urlPath:      "/service/teams/members/" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusForbidden,
+		},
 		{
 			name:         "Delete Team Members",
 			method:       "DELETE",
@@ -110,6 +374,213 @@ func TestRoutes(t *testing.T) {
 			body:         nil,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:         "Delete Team Forbidden",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Delete Team",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Restore Team Forbidden",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/restore",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Restore Team",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/restore",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Get Team By ID",
+			method:       "GET",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Put Team Not Allowed",
+			method:       "PUT",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String(),
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "List Own Team Memberships",
+			method:       "GET",
+			urlPath:      "/service/teams/memberships/me",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Leave Team",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/members/me",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Leave Team Owner",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/members/me",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Create Team Invitation",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/invitations",
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(app.testJSONTeamInvitation(t)),
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Create Team Invitation Forbidden",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/invitations",
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(app.testJSONTeamInvitation(t)),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "List Own Team Invitations",
+			method:       "GET",
+			urlPath:      "/service/teams/invitations/me",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Accept Team Invitation Other User",
+			method:       "POST",
+			urlPath:      "/service/teams/invitations/accept",
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(app.testJSONTeamInvitationToken(t, mocks.MockInvitationToken())),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Accept Team Invitation Invalid Token",
+			method:       "POST",
+			urlPath:      "/service/teams/invitations/accept",
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(app.testJSONTeamInvitationToken(t, "AAAAAAAAAAAAAAAAAAAAAAAAAA")),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Accept Team Invitation",
+			method:       "POST",
+			urlPath:      "/service/teams/invitations/accept",
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(app.testJSONTeamInvitationToken(t, mocks.MockInvitationToken())),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Decline Team Invitation",
+			method:       "POST",
+			urlPath:      "/service/teams/invitations/decline",
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(app.testJSONTeamInvitationToken(t, mocks.MockInvitationToken())),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Create Team Transfer",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer",
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(app.testJSONTeamTransfer(t, mocks.MockSecondUUID())),
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Create Team Transfer Forbidden",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer",
+			contentType:  "application/json",
+			token:        secondToken,
+			body:         strings.NewReader(app.testJSONTeamTransfer(t, mocks.MockSecondUUID())),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Create Team Transfer Not Member",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer",
+			contentType:  "application/json",
+			token:        firstToken,
+			body:         strings.NewReader(app.testJSONTeamTransfer(t, uuid.New())),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Get Team Transfer",
+			method:       "GET",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Accept Team Transfer Owner",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer/accept",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Accept Team Transfer",
+			method:       "POST",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer/accept",
+			contentType:  "",
+			token:        secondToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Cancel Team Transfer",
+			method:       "DELETE",
+			urlPath:      "/service/teams/" + mocks.MockFirstUUID().String() + "/transfer",
+			contentType:  "",
+			token:        firstToken,
+			body:         nil,
+			expectedCode: http.StatusOK,
+		},
 	}
 
 	for _, tt := range tests {
@@ -118,4 +589,500 @@ func TestRoutes(t *testing.T) {
 			assert.Equal(t, tt.expectedCode, actualCode)
 		})
 	}
+
+	t.Run("Team Picture Cache", func(t *testing.T) {
+		urlPath := "/service/teams/pictures/" + mocks.MockFirstUUID().String() + ".jpg"
+
+		_, header, _ := ts.request(t, "GET", urlPath, "", "", nil)
+		assert.Equal(t, "public, max-age=31536000, immutable", header.Get("Cache-Control"))
+		assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+		assert.Equal(t, "image/jpeg", header.Get("Content-Type"))
+		assert.NotEmpty(t, header.Get("Last-Modified"))
+
+		etag := header.Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		rq, _ := http.NewRequest("GET", ts.URL+urlPath, nil)
+		rq.Header.Set("If-None-Match", etag)
+
+		rs, err := ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, http.StatusNotModified, rs.StatusCode)
+
+		rq, _ = http.NewRequest("GET", ts.URL+urlPath, nil)
+		rq.Header.Set("Range", "bytes=0-9")
+
+		rs, err = ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, http.StatusPartialContent, rs.StatusCode)
+		assert.Equal(t, int64(10), rs.ContentLength)
+	})
+
+	t.Run("Team Picture Variants", func(t *testing.T) {
+		_, _, body := ts.request(t, "GET", "/service/teams/me", "", firstToken, nil)
+		assert.Contains(t, body, `"team_picture_variants"`)
+		assert.Contains(t, body, mocks.MockFirstUUID().String()+".jpg?size=256")
+	})
+
+	t.Run("Create Team Too Large", func(t *testing.T) {
+		app.Config.Picture.MaxUploadSize = 1024
+		defer func() { app.Config.Picture.MaxUploadSize = 10_485_760 }()
+
+		body, contentType := app.testFormTeam(t)
+		code, _, response := ts.request(t, "POST", "/service/teams", contentType, firstToken, body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+		assert.Contains(t, response, `"error"`)
+
+		// A body without a length is cut while it's read
+		body, contentType = app.testFormTeam(t)
+		code, _, _ = ts.request(t, "POST", "/service/teams", contentType, firstToken, io.MultiReader(body))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		code, header, body := ts.request(t, "GET", "/metrics", "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, header.Get("Content-Type"), "text/plain")
+		assert.Contains(t, body, `teams_http_requests_total{method="GET",route="/service/teams/me",status="200"}`)
+		assert.Contains(t, body, `teams_http_request_duration_seconds_bucket{method="PATCH",route="/service/teams/:id",status="200"`)
+		assert.Contains(t, body, `route="unmatched"`)
+
+		// The routes can be built again, the metrics are only registered once
+		assert.NotPanics(t, func() { app.Routes() })
+	})
+
+	t.Run("Request ID", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := app.Logger
+		app.Logger = jsonlog.New(&logs, jsonlog.LevelInfo)
+		defer func() { app.Logger = logger }()
+
+		rq, _ := http.NewRequest("GET", ts.URL+"/service/teams/me", nil)
+		rq.Header.Set("Authorization", "Bearer "+firstToken)
+		rq.Header.Set("X-Request-ID", "envoy-request-1")
+
+		rs, err := ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		assert.Equal(t, "envoy-request-1", rs.Header.Get("X-Request-ID"))
+		assert.Contains(t, logs.String(), `"request_id":"envoy-request-1"`)
+		assert.Contains(t, logs.String(), `"route":"/service/teams/me"`)
+		assert.Contains(t, logs.String(), `"user_id":"`+mocks.MockFirstUUID().String()+`"`)
+
+		// An ID which can't be written as it is into the logs is replaced
+		rq.Header.Set("X-Request-ID", `{"level":"FATAL"}`)
+
+		rs, err = ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		_, err = uuid.Parse(rs.Header.Get("X-Request-ID"))
+		assert.Nil(t, err)
+
+		// The error logs of a request carry its ID
+		logs.Reset()
+
+		r := app.contextSetRequestInfo(httptest.NewRequest("GET", "/service/teams/me", nil), &requestInfo{ID: "envoy-request-2"})
+		app.serverErrorResponse(httptest.NewRecorder(), r, errors.New("failure"))
+		assert.Contains(t, logs.String(), `"request_id":"envoy-request-2"`)
+	})
+
+	t.Run("Team ETag", func(t *testing.T) {
+		_, header, _ := ts.request(t, "GET", "/service/teams/me", "", firstToken, nil)
+		etag := header.Get("ETag")
+		assert.Equal(t, `"`+mocks.MockFirstUUID().String()+`-1"`, etag)
+
+		patch := func(ifMatch string) *http.Response {
+			body, contentType := app.testFormTeam(t)
+
+			rq, _ := http.NewRequest("PATCH", ts.URL+"/service/teams/"+mocks.MockFirstUUID().String(), body)
+			rq.Header.Set("Content-Type", contentType)
+			rq.Header.Set("Authorization", "Bearer "+firstToken)
+			if ifMatch != "" {
+				rq.Header.Set("If-Match", ifMatch)
+			}
+
+			rs, err := ts.Client().Do(rq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			return rs
+		}
+
+		// The update returns the tag of the new version
+		rs := patch(etag)
+		assert.Equal(t, http.StatusOK, rs.StatusCode)
+		assert.Equal(t, `"`+mocks.MockFirstUUID().String()+`-2"`, rs.Header.Get("ETag"))
+
+		rs = patch(`W/` + etag + `, "*"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rs.StatusCode)
+		assert.Equal(t, etag, rs.Header.Get("ETag"))
+
+		rs = patch(`"stale", *`)
+		assert.Equal(t, http.StatusOK, rs.StatusCode)
+
+		// A team is only deleted in the version the client read
+		rq, _ := http.NewRequest("DELETE", ts.URL+"/service/teams/"+mocks.MockFirstUUID().String(), nil)
+		rq.Header.Set("Authorization", "Bearer "+firstToken)
+		rq.Header.Set("If-Match", `"stale"`)
+
+		rs, err := ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, http.StatusPreconditionFailed, rs.StatusCode)
+
+		// A client which doesn't send its version is rejected once it's required
+		app.Config.Concurrency.RequireIfMatch = true
+		defer func() { app.Config.Concurrency.RequireIfMatch = false }()
+
+		rs = patch("")
+		assert.Equal(t, http.StatusPreconditionRequired, rs.StatusCode)
+	})
+
+	t.Run("Legacy Team Picture", func(t *testing.T) {
+		// A picture uploaded before was named by its owner with the extension of the file
+		legacy := mocks.MockSecondUUID().String() + ".PNG"
+		testPutFile(t, app.Storage, "./test/images/team.jpg", legacy)
+
+		teams := app.Models.Teams
+		app.Models.Teams = &testPictureTeamModel{picture: legacy}
+		defer func() { app.Models.Teams = teams }()
+
+		code, header, _ := ts.request(t, "GET", "/service/teams/pictures/"+legacy, "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "image/png", header.Get("Content-Type"))
+
+		// It has no thumbnails
+		code, _, _ = ts.request(t, "GET", "/service/teams/pictures/"+legacy+"?size=64", "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+
+		// It's deleted once no team refers to it
+		app.Models.Teams = &testPictureTeamModel{}
+		err := app.deleteTeamPicture(context.Background(), legacy)
+		assert.Nil(t, err)
+
+		code, _, _ = ts.request(t, "GET", "/service/teams/pictures/"+legacy, "", "", nil)
+		assert.Equal(t, http.StatusNotFound, code)
+
+		_, err = app.Storage.Get(context.Background(), legacy)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Team Changed Concurrently", func(t *testing.T) {
+		teams := app.Models.Teams
+		app.Models.Teams = &testRacedTeamModel{version: 1}
+		defer func() { app.Models.Teams = teams }()
+
+		send := func(method string, ifMatch string) *http.Response {
+			rq, _ := http.NewRequest(method, ts.URL+"/service/teams/"+mocks.MockFirstUUID().String(), strings.NewReader("team_name=Doe%27s+Team"))
+			rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rq.Header.Set("Authorization", "Bearer "+firstToken)
+			if ifMatch != "" {
+				rq.Header.Set("If-Match", ifMatch)
+			}
+
+			rs, err := ts.Client().Do(rq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			return rs
+		}
+
+		// The precondition held when it was checked, but not anymore when the team is written
+		rs := send("PATCH", `"`+mocks.MockFirstUUID().String()+`-1"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rs.StatusCode)
+		assert.Equal(t, `"`+mocks.MockFirstUUID().String()+`-2"`, rs.Header.Get("ETag"))
+
+		rs = send("DELETE", `"`+mocks.MockFirstUUID().String()+`-2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rs.StatusCode)
+		assert.Equal(t, `"`+mocks.MockFirstUUID().String()+`-3"`, rs.Header.Get("ETag"))
+
+		// A request without a precondition is a conflict
+		rs = send("PATCH", "")
+		assert.Equal(t, http.StatusConflict, rs.StatusCode)
+	})
+
+	t.Run("Idempotency Key", func(t *testing.T) {
+		send := func(url string, contentType string, token string, key string, body io.Reader) (*http.Response, string) {
+			rq, _ := http.NewRequest("POST", ts.URL+url, body)
+			rq.Header.Set("Content-Type", contentType)
+			rq.Header.Set("Authorization", "Bearer "+token)
+			rq.Header.Set("Idempotency-Key", key)
+
+			rs, err := ts.Client().Do(rq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			response, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return rs, string(response)
+		}
+
+		post := func(token string, key string, body string) (*http.Response, string) {
+			return send("/service/teams/members", "application/json", token, key, strings.NewReader(body))
+		}
+
+		body, err := io.ReadAll(app.testJSONTeamMember(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rs, first := post(firstToken, "retry-1", string(body))
+		assert.Equal(t, http.StatusCreated, rs.StatusCode)
+		assert.Empty(t, rs.Header.Get("Idempotent-Replayed"))
+
+		// A retry gets the original response
+		rs, replay := post(firstToken, "retry-1", string(body))
+		assert.Equal(t, http.StatusCreated, rs.StatusCode)
+		assert.Equal(t, "true", rs.Header.Get("Idempotent-Replayed"))
+		assert.Equal(t, first, replay)
+
+		// The key can't be used for another request
+		rs, _ = post(firstToken, "retry-1", `{"team_member_team": "`+mocks.MockFirstUUID().String()+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rs.StatusCode)
+
+		// The keys of the users are apart
+		rs, _ = post(secondToken, "retry-1", string(body))
+		assert.Empty(t, rs.Header.Get("Idempotent-Replayed"))
+
+		// A request still being handled isn't run again
+		key := &data.IdempotencyKey{Key: "retry-2", User: mocks.MockFirstUUID()}
+		_, err = app.Models.IdempotencyKeys.Claim(context.Background(), key, time.Hour, time.Minute)
+		assert.Nil(t, err)
+
+		rs, _ = post(firstToken, "retry-2", string(body))
+		assert.Equal(t, http.StatusConflict, rs.StatusCode)
+
+		rs, _ = post(firstToken, "retry 3", string(body))
+		assert.Equal(t, http.StatusBadRequest, rs.StatusCode)
+
+		// A retry of a multipart form has another boundary
+		form, contentType := app.testFormTeam(t)
+		rs, first = send("/service/teams", contentType, firstToken, "retry-4", form)
+		assert.Equal(t, http.StatusCreated, rs.StatusCode)
+		assert.NotEmpty(t, rs.Header.Get("ETag"))
+		assert.NotEmpty(t, rs.Header.Get("Location"))
+
+		form, retryContentType := app.testFormTeam(t)
+		assert.NotEqual(t, contentType, retryContentType)
+
+		retry, replay := send("/service/teams", retryContentType, firstToken, "retry-4", form)
+		assert.Equal(t, http.StatusCreated, retry.StatusCode)
+		assert.Equal(t, "true", retry.Header.Get("Idempotent-Replayed"))
+		assert.Equal(t, rs.Header.Get("Content-Type"), retry.Header.Get("Content-Type"))
+		assert.Equal(t, rs.Header.Get("ETag"), retry.Header.Get("ETag"))
+		assert.Equal(t, rs.Header.Get("Location"), retry.Header.Get("Location"))
+		assert.Equal(t, first, replay)
+	})
+
+	t.Run("Readiness", func(t *testing.T) {
+		code, _, body := ts.request(t, "GET", "/service/teams/health/live", "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"status": "alive"`)
+
+		code, _, body = ts.request(t, "GET", "/service/teams/health/ready", "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"status": "available"`)
+		assert.Contains(t, body, `"latency_ms"`)
+
+		// The team events wait in the outbox while the indexing service is down
+		indexing := app.IndexingHealth.(*testHealthClient)
+		indexing.status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		defer func() { indexing.status = grpc_health_v1.HealthCheckResponse_SERVING }()
+
+		code, _, body = ts.request(t, "GET", "/service/teams/health/ready", "", "", nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"status": "degraded"`)
+
+		// The service can't serve any request without the database
+		db := app.DB
+		app.DB = sql.OpenDB(&testConnector{err: errors.New("connection refused")})
+		defer func() { app.DB = db }()
+
+		code, _, body = ts.request(t, "GET", "/service/teams/health/ready", "", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, body, `"status": "unavailable"`)
+
+		// The probe file doesn't stay in the storage
+		objects, err := app.Storage.List(context.Background())
+		assert.Nil(t, err)
+		for _, object := range objects {
+			assert.NotEqual(t, readinessProbe, object.Name)
+		}
+	})
+
+	t.Run("Log Level", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := app.Logger
+		app.Logger = jsonlog.New(&logs, jsonlog.LevelInfo)
+		defer func() { app.Logger = logger }()
+
+		code, _, _ := ts.request(t, "PUT", "/service/teams/admin/log-level", "application/json", secondToken, strings.NewReader(`{"level": "debug"}`))
+		assert.Equal(t, http.StatusForbidden, code)
+
+		code, _, _ = ts.request(t, "PUT", "/service/teams/admin/log-level", "application/json", firstToken, strings.NewReader(`{"level": "verbose"}`))
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+		code, _, body := ts.request(t, "PUT", "/service/teams/admin/log-level", "application/json", firstToken, strings.NewReader(`{"level": "warn"}`))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"level": "WARN"`)
+		assert.Equal(t, jsonlog.LevelWarn, app.Logger.Level())
+
+		// The access logs are INFO, so they're not written anymore
+		logs.Reset()
+
+		code, _, body = ts.request(t, "GET", "/service/teams/admin/log-level", "", firstToken, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, `"level": "WARN"`)
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		ts := testServer(t, app.Routes())
+		defer ts.Close()
+
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+		rq, _ := http.NewRequest("GET", ts.URL+"/service/teams/"+mocks.MockFirstUUID().String(), nil)
+		rq.Header.Set("Authorization", "Bearer "+firstToken)
+		rq.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+		rs, err := ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		spans := recorder.Ended()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "GET /service/teams/:id", spans[0].Name())
+			assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+			assert.True(t, spans[0].Parent().IsRemote())
+		}
+	})
+
+	t.Run("Problem Details", func(t *testing.T) {
+		rq, _ := http.NewRequest("GET", ts.URL+"/service/teams/"+uuid.New().String(), nil)
+		rq.Header.Set("Authorization", "Bearer "+firstToken)
+		rq.Header.Set("Accept", "application/problem+json")
+
+		rs, err := ts.Client().Do(rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		var problem map[string]interface{}
+		err = json.NewDecoder(rs.Body).Decode(&problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, http.StatusNotFound, rs.StatusCode)
+		assert.Equal(t, "application/problem+json", rs.Header.Get("Content-Type"))
+		assert.Equal(t, "Not Found", problem["title"])
+		assert.Equal(t, float64(http.StatusNotFound), problem["status"])
+		assert.NotEmpty(t, problem["detail"])
+	})
+
+	t.Run("Method Not Allowed", func(t *testing.T) {
+		code, header, _ := ts.request(t, "DELETE", "/service/teams/members", "", firstToken, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+		assert.Equal(t, "GET, OPTIONS, POST", header.Get("Allow"))
+
+		// The static segments of the main router aren't a team ID
+		code, header, _ = ts.request(t, "PUT", "/service/teams/me", "", firstToken, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+		assert.Equal(t, "GET, OPTIONS", header.Get("Allow"))
+
+		// The team router answers for its own paths
+		code, header, _ = ts.request(t, "PUT", "/service/teams/"+mocks.MockFirstUUID().String(), "", firstToken, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+		assert.Equal(t, "DELETE, GET, OPTIONS, PATCH", header.Get("Allow"))
+	})
+
+	t.Run("Team Picture Discarded", func(t *testing.T) {
+		file, err := os.Open("./test/images/team.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		pic, err := picture.Process(file, 4096, 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The picture is stored before the name is read
+		body, contentType := app.testFormTeamPictureFirst(t, strings.Repeat("a", 1024))
+		code, _, _ := ts.request(t, "POST", "/service/teams", contentType, firstToken, body)
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+		_, err = app.Storage.Get(context.Background(), pic.Name())
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+
+		// The team without the new picture isn't updated
+		body, contentType = app.testFormTeamPictureFirst(t, "Doe's\x00Team")
+		code, _, _ = ts.request(t, "PATCH", "/service/teams/"+mocks.MockFirstUUID().String(), contentType, firstToken, body)
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+		_, err = app.Storage.Get(context.Background(), pic.Name())
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+		_, err = app.Storage.Get(context.Background(), picture.VariantName(pic.Name(), 64))
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("Team Pictures Sweeper", func(t *testing.T) {
+		orphan := strings.Repeat("a", 64) + ".jpg"
+		testPutFile(t, app.Storage, "./test/images/team.jpg", orphan)
+		testPutFile(t, app.Storage, "./test/images/team.jpg", picture.VariantName(orphan, 64))
+
+		app.sweepTeamPicturesBatch(context.Background())
+
+		_, err := app.Storage.Get(context.Background(), orphan)
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+		_, err = app.Storage.Get(context.Background(), picture.VariantName(orphan, 64))
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+
+		blob, err := app.Storage.Get(context.Background(), mocks.MockFirstUUID().String()+".jpg")
+		if assert.Nil(t, err) {
+			blob.Close()
+		}
+	})
+
+	t.Run("Team Invitation Email", func(t *testing.T) {
+		// Wait for the emails sent in the background
+		app.wg.Wait()
+
+		messages := app.Mailer.(*mailer.Memory).Messages()
+		assert.Len(t, messages, 1)
+		assert.Equal(t, "nina@doe.com", messages[0].Recipient)
+		assert.Contains(t, messages[0].Body, "token=")
+	})
 }
@@ -2,6 +2,10 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -14,15 +18,30 @@ import (
 	"github.com/e-inwork-com/go-team-service/internal/data"
 	"github.com/e-inwork-com/go-team-service/internal/data/mocks"
 	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/mailer"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func testApplication(t *testing.T) *Application {
 
 	var cfg Config
 	cfg.Auth.Secret = "secret"
-	cfg.Uploads = "../local/test/uploads"
+	cfg.Admin.Users = []uuid.UUID{mocks.MockFirstUUID()}
+	cfg.Storage.Driver = "memory"
+	cfg.Invitation.TTL = time.Hour
+	cfg.Picture.MaxWidth = 4096
+	cfg.Picture.MaxHeight = 4096
+	cfg.Picture.MaxUploadSize = 10_485_760
+	cfg.Health.Timeout = time.Second
+	cfg.Idempotency.TTL = time.Hour
+
+	// Put the picture of the mock team in the storage
+	store := storage.NewMemory()
+	testPutFile(t, store, "./test/images/team.jpg", mocks.MockFirstUUID().String()+".jpg")
 
 	return &Application{
 		Config: cfg,
@@ -31,9 +50,113 @@ func testApplication(t *testing.T) *Application {
 			Teams:       &mocks.TeamModel{},
 			Users:       &mocks.UserModel{},
 			TeamMembers: &mocks.TeamMemberModel{},
+			TeamEvents:  &mocks.TeamEventModel{},
+
+			TeamInvitations: &mocks.TeamInvitationModel{},
+			TeamTransfers:   &mocks.TeamTransferModel{},
+			IdempotencyKeys: &mocks.IdempotencyKeyModel{},
 		},
+		DB:             sql.OpenDB(&testConnector{}),
+		IndexingHealth: &testHealthClient{status: grpc_health_v1.HealthCheckResponse_SERVING},
+		Mailer:         mailer.NewMemory(),
+		Storage:        store,
+	}
+
+}
+
+// testConnector opens the connections of a database pool without a database,
+// a connection fails while err is set
+type testConnector struct {
+	err error
+}
+
+func (c *testConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return testConn{}, nil
+}
+
+func (c *testConnector) Driver() driver.Driver {
+	return nil
+}
+
+// testConn only answers to the pings of the pool
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (testConn) Close() error {
+	return nil
+}
+
+func (testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+// testHealthClient answers to the health checks of the indexing service
+type testHealthClient struct {
+	grpc_health_v1.HealthClient
+	status grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func (c *testHealthClient) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	return &grpc_health_v1.HealthCheckResponse{Status: c.status}, nil
+}
+
+// testRacedTeamModel is a team which another request changes
+// between the read and the write of every request
+type testRacedTeamModel struct {
+	mocks.TeamModel
+	version int
+}
+
+func (m *testRacedTeamModel) GetByID(ctx context.Context, id uuid.UUID) (*data.Team, error) {
+	team, err := m.TeamModel.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	team.Version = m.version
+
+	return team, nil
+}
+
+func (m *testRacedTeamModel) Update(ctx context.Context, team *data.Team) error {
+	m.version++
+
+	return data.ErrEditConflict
+}
+
+func (m *testRacedTeamModel) Delete(ctx context.Context, team *data.Team) error {
+	m.version++
+
+	return data.ErrEditConflict
+}
+
+// testPictureTeamModel is a team which refers to the given picture
+type testPictureTeamModel struct {
+	mocks.TeamModel
+	picture string
+}
+
+func (m *testPictureTeamModel) PictureReferenced(ctx context.Context, teamPicture string, includeDeleted bool) (bool, error) {
+	return teamPicture == m.picture, nil
+}
+
+func testPutFile(t *testing.T, store storage.Storage, src string, name string) {
+	buffer, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
 	}
 
+	err = store.Put(context.Background(), name, bytes.NewReader(buffer), int64(len(buffer)), http.DetectContentType(buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
 type httpTestServer struct {
@@ -144,6 +267,28 @@ func (app *Application) testFormTeam(t *testing.T) (io.Reader, string) {
 	return bodyBuf, contentType
 }
 
+// testFormTeamPictureFirst returns a form of a team with the picture before the name
+func (app *Application) testFormTeamPictureFirst(t *testing.T, name string) (io.Reader, string) {
+	bodyBuf := &bytes.Buffer{}
+	bodyWriter := multipart.NewWriter(bodyBuf)
+
+	picture, err := os.ReadFile("./test/images/team.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileWriter, err := bodyWriter.CreateFormFile("team_picture", "team.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileWriter.Write(picture)
+
+	bodyWriter.WriteField("team_name", name)
+	bodyWriter.Close()
+
+	return bodyBuf, bodyWriter.FormDataContentType()
+}
+
 func (app *Application) testJSONTeamMember(t *testing.T) io.Reader {
 	teamMember := fmt.Sprintf(
 		`{"team_member_team": "%v", "team_member_user":  "%v"}`,
@@ -152,3 +297,15 @@ func (app *Application) testJSONTeamMember(t *testing.T) io.Reader {
 
 	return bytes.NewReader([]byte(teamMember))
 }
+
+func (app *Application) testJSONTeamInvitation(t *testing.T) string {
+	return `{"team_invitation_email": "nina@doe.com", "team_invitation_role": "member"}`
+}
+
+func (app *Application) testJSONTeamInvitationToken(t *testing.T, token string) string {
+	return fmt.Sprintf(`{"token": "%v"}`, token)
+}
+
+func (app *Application) testJSONTeamTransfer(t *testing.T, to uuid.UUID) string {
+	return fmt.Sprintf(`{"team_transfer_to": "%v"}`, to)
+}
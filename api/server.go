@@ -13,7 +13,21 @@ import (
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
 	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/mailer"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
 	_ "github.com/lib/pq"
 )
@@ -23,21 +37,35 @@ var (
 	Version   string
 )
 
+// shutdownTimeout bounds the end of the requests and then the end of
+// the background workers, once the server is asked to stop
+const shutdownTimeout = 5 * time.Second
+
 type Config struct {
 	Port int
 	Env  string
 
+	Log struct {
+		Level       jsonlog.Level
+		StackTraces bool
+	}
+
 	Db struct {
-		Dsn         string
-		MaxOpenConn int
-		MaxIdleConn int
-		MaxIdleTime string
+		Dsn          string
+		MaxOpenConn  int
+		MaxIdleConn  int
+		MaxIdleTime  string
+		QueryTimeout time.Duration
 	}
 
 	Auth struct {
 		Secret string
 	}
 
+	Admin struct {
+		Users []uuid.UUID
+	}
+
 	Limiter struct {
 		Enabled bool
 		Rps     float64
@@ -48,15 +76,94 @@ type Config struct {
 		TrustedOrigins []string
 	}
 
-	Uploads  string
-	GRPCTeam string
+	Outbox struct {
+		Interval    time.Duration
+		BatchSize   int
+		MaxAttempts int
+	}
+
+	Reconciler struct {
+		Interval    time.Duration
+		BatchSize   int
+		Concurrency int
+	}
+
+	Purge struct {
+		Interval  time.Duration
+		Retention time.Duration
+		BatchSize int
+	}
+
+	Sweeper struct {
+		Interval    time.Duration
+		GracePeriod time.Duration
+	}
+
+	Health struct {
+		Timeout time.Duration
+	}
+
+	Concurrency struct {
+		RequireIfMatch bool
+	}
+
+	Idempotency struct {
+		TTL time.Duration
+	}
+
+	SMTP struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+		Sender   string
+	}
+
+	Invitation struct {
+		TTL time.Duration
+		URL string
+	}
+
+	Storage struct {
+		Driver string
+		S3     struct {
+			Endpoint  string
+			Region    string
+			Bucket    string
+			AccessKey string
+			SecretKey string
+			UseSSL    bool
+		}
+	}
+
+	Picture struct {
+		MaxWidth      int
+		MaxHeight     int
+		MaxUploadSize int64
+	}
+
+	Tracing struct {
+		Exporter    string
+		Endpoint    string
+		Insecure    bool
+		SampleRatio float64
+	}
+
+	Uploads         string
+	GRPCTeam        string
+	GRPCTeamTimeout time.Duration
 }
 
 type Application struct {
-	Config Config
-	Logger *jsonlog.Logger
-	Models data.Models
-	wg     sync.WaitGroup
+	Config         Config
+	Logger         *jsonlog.Logger
+	DB             *sql.DB
+	Models         data.Models
+	TeamIndexing   teams.TeamServiceClient
+	IndexingHealth grpc_health_v1.HealthClient
+	Mailer         mailer.Mailer
+	Storage        storage.Storage
+	wg             sync.WaitGroup
 }
 
 func (app *Application) Serve() error {
@@ -70,6 +177,34 @@ func (app *Application) Serve() error {
 
 	shutdownError := make(chan error)
 
+	// Publish the statistics of the connection pool
+	registerDBStats(app.DB)
+
+	// Start the dispatcher of the team indexing events, the reconciler, the purge
+	// jobs and the pictures sweeper, they are stopped after the server has been shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.background(func() {
+		app.dispatchTeamEvents(ctx)
+	})
+
+	app.background(func() {
+		app.reconcileTeams(ctx)
+	})
+
+	app.background(func() {
+		app.purgeTeams(ctx)
+	})
+
+	app.background(func() {
+		app.purgeIdempotencyKeys(ctx)
+	})
+
+	app.background(func() {
+		app.sweepTeamPictures(ctx)
+	})
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -79,10 +214,14 @@ func (app *Application) Serve() error {
 			"signal": s.String(),
 		})
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+
+		err := srv.Shutdown(shutdownCtx)
+
+		// Stop the background workers after the last requests
+		cancel()
 
-		err := srv.Shutdown(ctx)
 		if err != nil {
 			shutdownError <- err
 		}
@@ -143,3 +282,83 @@ func OpenDB(cfg Config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func OpenGRPCTeam(cfg Config) (*grpc.ClientConn, error) {
+	// The connection is established lazily,
+	// so the indexing service doesn't have to be up yet.
+	// The trace of a call continues into the indexing service.
+	con, err := grpc.Dial(cfg.GRPCTeam,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor(otelgrpc.WithPropagators(propagator))))
+	if err != nil {
+		return nil, err
+	}
+
+	return con, nil
+}
+
+// OpenStorage returns the storage of the uploaded files
+func OpenStorage(cfg Config) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case "filesystem":
+		return storage.NewFilesystem(cfg.Uploads), nil
+	case "s3":
+		return storage.NewS3(
+			cfg.Storage.S3.Endpoint,
+			cfg.Storage.S3.Region,
+			cfg.Storage.S3.Bucket,
+			cfg.Storage.S3.AccessKey,
+			cfg.Storage.S3.SecretKey,
+			cfg.Storage.S3.UseSSL)
+	case "memory":
+		return storage.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// OpenTracing sets the tracer provider of the exporter of the config, it returns
+// the function which flushes the remaining spans when the application stops
+func OpenTracing(cfg Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Tracing.Exporter {
+	case "otlp":
+		var options []otlptracehttp.Option
+		if cfg.Tracing.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint))
+		}
+		if cfg.Tracing.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "none":
+		// Keep the no-op tracer provider, the trace
+		// context of the requests is still propagated
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName("go-team-service"),
+			semconv.ServiceVersion(Version),
+			semconv.DeploymentEnvironment(cfg.Env),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
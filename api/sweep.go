@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+)
+
+// sweepMetrics counts the files checked and deleted by the sweeper
+var sweepMetrics = expvar.NewMap("team_pictures_sweeper")
+
+// sweepTeamPictures periodically deletes the stored pictures which no team
+// refers to, like the pictures left behind by a failed request
+func (app *Application) sweepTeamPictures(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Sweeper.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.sweepTeamPicturesBatch(ctx)
+		}
+	}
+}
+
+func (app *Application) sweepTeamPicturesBatch(ctx context.Context) {
+	sweepMetrics.Add("runs", 1)
+
+	objects, err := app.Storage.List(ctx)
+	if err != nil {
+		sweepMetrics.Add("errors", 1)
+		app.Logger.PrintError(err, map[string]string{
+			"task": "sweep team pictures",
+		})
+		return
+	}
+
+	// A picture is stored before the team refers to it,
+	// so the recent files may belong to a running request
+	modifiedBefore := time.Now().Add(-app.Config.Sweeper.GracePeriod)
+
+	// A picture is checked once for all its thumbnails
+	referenced := make(map[string]bool)
+
+	for _, object := range objects {
+		if ctx.Err() != nil {
+			return
+		}
+
+		sweepMetrics.Add("scanned", 1)
+
+		name, ok := picture.OriginalName(object.Name)
+		if !ok || object.ModTime.After(modifiedBefore) {
+			continue
+		}
+
+		isReferenced, checked := referenced[name]
+		if !checked {
+			isReferenced, err = app.Models.Teams.PictureReferenced(ctx, name, true)
+			if err != nil {
+				sweepMetrics.Add("errors", 1)
+				app.Logger.PrintError(err, map[string]string{
+					"task":    "sweep team pictures",
+					"picture": name,
+				})
+				continue
+			}
+
+			referenced[name] = isReferenced
+		}
+
+		if isReferenced {
+			continue
+		}
+
+		err = app.Storage.Delete(ctx, object.Name)
+		if err != nil {
+			sweepMetrics.Add("errors", 1)
+			app.Logger.PrintError(err, map[string]string{
+				"task":    "sweep team pictures",
+				"picture": object.Name,
+			})
+			continue
+		}
+
+		sweepMetrics.Add("deleted", 1)
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+)
+
+func (app *Application) createTeamInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TeamInvitationEmail string `json:"team_invitation_email"`
+		TeamInvitationRole  string `json:"team_invitation_role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Check team exist
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// An invitee gets the member role by default
+	if input.TeamInvitationRole == "" {
+		input.TeamInvitationRole = data.RoleMember
+	}
+
+	// Only team's owner and admins can invite a member,
+	// and only the owner can invite an admin
+	if !app.authorizeTeam(w, r, actionManageMembers, team) {
+		return
+	}
+
+	if input.TeamInvitationRole == data.RoleAdmin && !app.authorizeTeam(w, r, actionManageAdmins, team) {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	teamInvitation := &data.TeamInvitation{
+		TeamInvitationTeam:     team.ID,
+		TeamInvitationTeamName: team.TeamName,
+		TeamInvitationInviter:  user.ID,
+		TeamInvitationEmail:    strings.TrimSpace(input.TeamInvitationEmail),
+		TeamInvitationRole:     input.TeamInvitationRole,
+	}
+
+	v := validator.New()
+	if data.ValidateTeamInvitation(v, teamInvitation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Create a single-use token
+	err = data.GenerateTeamInvitationToken(teamInvitation, app.Config.Invitation.TTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.Models.TeamInvitations.Insert(r.Context(), teamInvitation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Send the token to the invitee, the email doesn't
+	// have to belong to a registered user yet
+	app.background(func() {
+		err := app.sendTeamInvitation(teamInvitation, user)
+		if err != nil {
+			app.Logger.PrintError(err, map[string]string{
+				"task":               "team invitation",
+				"team_invitation_id": teamInvitation.ID.String(),
+			})
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"team_invitation": teamInvitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) sendTeamInvitation(teamInvitation *data.TeamInvitation, inviter *data.User) error {
+	link := fmt.Sprintf("%s?token=%s", app.Config.Invitation.URL, url.QueryEscape(teamInvitation.Token))
+
+	subject := fmt.Sprintf("You are invited to join %s", teamInvitation.TeamInvitationTeamName)
+	body := fmt.Sprintf(
+		"Hi,\r\n\r\n%s %s invited you to join the team %s as %s.\r\n\r\n"+
+			"Sign in or sign up with this email address, then accept or decline the invitation:\r\n%s\r\n\r\n"+
+			"Your invitation token is %s, it expires on %s.\r\n",
+		inviter.FirstName,
+		inviter.LastName,
+		teamInvitation.TeamInvitationTeamName,
+		teamInvitation.TeamInvitationRole,
+		link,
+		teamInvitation.Token,
+		teamInvitation.TeamInvitationExpiry.Format("2006-01-02 15:04 MST"),
+	)
+
+	return app.Mailer.Send(teamInvitation.TeamInvitationEmail, subject, body)
+}
+
+func (app *Application) listOwnTeamInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the current user
+	user := app.contextGetUser(r)
+
+	// Get the pending invitations sent to the email of the user
+	teamInvitations, err := app.Models.TeamInvitations.ListByEmail(r.Context(), user.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_invitations": teamInvitations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readTeamInvitation reads the token from the request body and returns
+// the pending invitation if it was sent to the current user
+func (app *Application) readTeamInvitation(w http.ResponseWriter, r *http.Request) (*data.TeamInvitation, bool) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	v := validator.New()
+	if data.ValidateTeamInvitationToken(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, false
+	}
+
+	teamInvitation, err := app.Models.TeamInvitations.GetByToken(r.Context(), input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired invitation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	// Only the invitee can use the token
+	user := app.contextGetUser(r)
+	if !strings.EqualFold(teamInvitation.TeamInvitationEmail, user.Email) {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return teamInvitation, true
+}
+
+func (app *Application) acceptTeamInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	teamInvitation, ok := app.readTeamInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	// Get the current user
+	user := app.contextGetUser(r)
+
+	// Accept the invitation and add the user to the team
+	err := app.Models.TeamInvitations.Accept(r.Context(), teamInvitation, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Get the Team Member just created
+	teamMember, err := app.Models.TeamMembers.GetByTeamAndUser(r.Context(), teamInvitation.TeamInvitationTeam, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_member": teamMember}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) declineTeamInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	teamInvitation, ok := app.readTeamInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	// Decline the invitation
+	err := app.Models.TeamInvitations.Decline(r.Context(), teamInvitation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_invitation": teamInvitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -13,6 +13,7 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 	var input struct {
 		TeamMemberTeam uuid.UUID `json:"team_member_team"`
 		TeamMemberUser uuid.UUID `json:"team_member_user"`
+		TeamMemberRole string    `json:"team_member_role"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -22,7 +23,7 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Check team exist
-	team, err := app.Models.Teams.GetByID(input.TeamMemberTeam)
+	team, err := app.Models.Teams.GetByID(r.Context(), input.TeamMemberTeam)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -34,7 +35,7 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Check user exist
-	_, err = app.Models.Users.GetByID(input.TeamMemberUser)
+	_, err = app.Models.Users.GetByID(r.Context(), input.TeamMemberUser)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -45,16 +46,25 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Only team's owner can add a member user
-	user := app.contextGetUser(r)
-	if team.TeamUser != user.ID {
-		app.notPermittedResponse(w, r)
+	// A new member gets the member role by default
+	if input.TeamMemberRole == "" {
+		input.TeamMemberRole = data.RoleMember
+	}
+
+	// Only team's owner and admins can add a member user,
+	// and only the owner can add an admin
+	if !app.authorizeTeam(w, r, actionManageMembers, team) {
+		return
+	}
+
+	if input.TeamMemberRole == data.RoleAdmin && !app.authorizeTeam(w, r, actionManageAdmins, team) {
 		return
 	}
 
 	teamMember := &data.TeamMember{
 		TeamMemberTeam: input.TeamMemberTeam,
 		TeamMemberUser: input.TeamMemberUser,
+		TeamMemberRole: input.TeamMemberRole,
 	}
 
 	v := validator.New()
@@ -63,9 +73,11 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err = app.Models.TeamMembers.Insert(teamMember)
+	err = app.Models.TeamMembers.Insert(r.Context(), teamMember)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrCreateConflict), errors.Is(err, data.ErrInvalidReference), errors.Is(err, data.ErrInvalidValue):
+			app.constraintViolationResponse(w, r, err)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -73,7 +85,7 @@ func (app *Application) createTeamMemberHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get the Team Member just created
-	teamMember, err = app.Models.TeamMembers.GetByID(teamMember.ID)
+	teamMember, err = app.Models.TeamMembers.GetByID(r.Context(), teamMember.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -99,7 +111,7 @@ func (app *Application) deleteTeamMemberHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get Team Member from the database
-	teamMember, err := app.Models.TeamMembers.GetByID(id)
+	teamMember, err := app.Models.TeamMembers.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -111,7 +123,7 @@ func (app *Application) deleteTeamMemberHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get a Team from the database
-	team, err := app.Models.Teams.GetByID(teamMember.TeamMemberTeam)
+	team, err := app.Models.Teams.GetByID(r.Context(), teamMember.TeamMemberTeam)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -122,17 +134,18 @@ func (app *Application) deleteTeamMemberHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Get the current user
-	user := app.contextGetUser(r)
+	// Only team's owner and admins can remove a member,
+	// and only the owner can remove an admin
+	if !app.authorizeTeam(w, r, actionManageMembers, team) {
+		return
+	}
 
-	// Check if the record has a related to the current user
-	if team.TeamUser != user.ID {
-		app.notPermittedResponse(w, r)
+	if teamMember.TeamMemberRole == data.RoleAdmin && !app.authorizeTeam(w, r, actionManageAdmins, team) {
 		return
 	}
 
 	// Delete Team Member
-	err = app.Models.TeamMembers.Delete(teamMember)
+	err = app.Models.TeamMembers.Delete(r.Context(), teamMember)
 	if err != nil {
 		switch {
 		default:
@@ -152,8 +165,24 @@ func (app *Application) listTeamMembersByOwnerHandler(w http.ResponseWriter, r *
 	// Get the current user
 	user := app.contextGetUser(r)
 
-	// Get a Team from the database
-	team, err := app.Models.Teams.GetByTeamUser(user.ID)
+	// Get a Team from the database, the first team
+	// of the user unless a team is requested
+	var team *data.Team
+	var err error
+
+	qs := r.URL.Query()
+	if qs.Has("team_member_team") {
+		var teamID uuid.UUID
+		teamID, err = uuid.Parse(qs.Get("team_member_team"))
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		team, err = app.Models.Teams.GetByID(r.Context(), teamID)
+	} else {
+		team, err = app.Models.Teams.GetByTeamUser(r.Context(), user.ID)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -164,8 +193,40 @@ func (app *Application) listTeamMembersByOwnerHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	// Check the current user can list the members of the team
+	if !app.authorizeTeam(w, r, actionListMembers, team) {
+		return
+	}
+
+	// Read the filters of the list
+	var input struct {
+		Name  string
+		Roles []string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Roles = app.readCSV(qs, "team_member_role", []string{})
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "created_at")
+	input.Filters.SortSafelist = []string{"created_at", "name", "-created_at", "-name"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+
+	for _, role := range input.Roles {
+		v.Check(validator.In(role, data.RoleAdmin, data.RoleMember, data.RoleViewer), "team_member_role", "must be admin, member or viewer")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	// Get list
-	teamMembers, err := app.Models.TeamMembers.ListByOwner(team.ID)
+	teamMembers, metadata, err := app.Models.TeamMembers.ListByTeam(r.Context(), team.ID, input.Name, input.Roles, input.Filters)
 	if err != nil {
 		switch {
 		default:
@@ -175,7 +236,7 @@ func (app *Application) listTeamMembersByOwnerHandler(w http.ResponseWriter, r *
 	}
 
 	// Response
-	err = app.writeJSON(w, http.StatusOK, envelope{"team_members": teamMembers}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_members": teamMembers, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -190,7 +251,7 @@ func (app *Application) getTeamMemberHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Get Team Member from the database
-	teamMember, err := app.Models.TeamMembers.GetByID(id)
+	teamMember, err := app.Models.TeamMembers.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -202,7 +263,7 @@ func (app *Application) getTeamMemberHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Get a Team
-	team, err := app.Models.Teams.GetByID(teamMember.TeamMemberTeam)
+	team, err := app.Models.Teams.GetByID(r.Context(), teamMember.TeamMemberTeam)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -216,9 +277,9 @@ func (app *Application) getTeamMemberHandler(w http.ResponseWriter, r *http.Requ
 	// Get the current user
 	user := app.contextGetUser(r)
 
-	// Check if the record has a related to the current user
-	if teamMember.TeamMemberUser != user.ID && team.TeamUser != user.ID {
-		app.notPermittedResponse(w, r)
+	// A member can always get the own record, others
+	// need the permission to list the members of the team
+	if teamMember.TeamMemberUser != user.ID && !app.authorizeTeam(w, r, actionListMembers, team) {
 		return
 	}
 
@@ -228,3 +289,145 @@ func (app *Application) getTeamMemberHandler(w http.ResponseWriter, r *http.Requ
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *Application) patchTeamMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TeamMemberRole string `json:"team_member_role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Get Team Member from the database
+	teamMember, err := app.Models.TeamMembers.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Get a Team
+	team, err := app.Models.Teams.GetByID(r.Context(), teamMember.TeamMemberTeam)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the owner of the team can change the roles
+	if !app.authorizeTeam(w, r, actionChangeRoles, team) {
+		return
+	}
+
+	teamMember.TeamMemberRole = input.TeamMemberRole
+
+	v := validator.New()
+	if data.ValidateTeamMember(v, teamMember); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Update the role
+	err = app.Models.TeamMembers.UpdateRole(r.Context(), teamMember)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrInvalidValue):
+			app.constraintViolationResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Send a request response
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_member": teamMember}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listOwnTeamMembershipsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the current user
+	user := app.contextGetUser(r)
+
+	// Get the teams where the user is a member
+	teamMembers, err := app.Models.TeamMembers.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Response
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_members": teamMembers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) leaveTeamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Get a Team from the database
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Get the current user
+	user := app.contextGetUser(r)
+
+	// The team can't be left without an owner
+	if team.TeamUser == user.ID {
+		app.ownerCannotLeaveResponse(w, r)
+		return
+	}
+
+	// Remove the current user from the team
+	err = app.Models.TeamMembers.DeleteByTeamAndUser(r.Context(), team.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Send a request response
+	err = app.writeJSON(w, http.StatusOK, nil, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
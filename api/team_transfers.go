@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+	"github.com/google/uuid"
+)
+
+func (app *Application) createTeamTransferHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TeamTransferTo uuid.UUID `json:"team_transfer_to"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Check team exist
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the owner can hand over the team
+	if !app.authorizeTeam(w, r, actionTransferTeam, team) {
+		return
+	}
+
+	teamTransfer := &data.TeamTransfer{
+		TeamTransferTeam: team.ID,
+		TeamTransferFrom: team.TeamUser,
+		TeamTransferTo:   input.TeamTransferTo,
+	}
+
+	v := validator.New()
+	if data.ValidateTeamTransfer(v, teamTransfer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The new owner has to be a member of the team
+	_, err = app.Models.TeamMembers.GetByTeamAndUser(r.Context(), team.ID, teamTransfer.TeamTransferTo)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("team_transfer_to", "must be a member of the team")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.Models.TeamTransfers.Insert(r.Context(), teamTransfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"team_transfer": teamTransfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readTeamTransfer returns the team of the ID parameter and its pending
+// transfer, if the current user is the owner or the nominated member
+func (app *Application) readTeamTransfer(w http.ResponseWriter, r *http.Request) (*data.Team, *data.TeamTransfer, bool) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, nil, false
+	}
+
+	// Check team exist
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	// Get the pending transfer of the team
+	teamTransfer, err := app.Models.TeamTransfers.GetPendingByTeam(r.Context(), team.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	user := app.contextGetUser(r)
+	if user.ID != team.TeamUser && user.ID != teamTransfer.TeamTransferTo {
+		app.notPermittedResponse(w, r)
+		return nil, nil, false
+	}
+
+	return team, teamTransfer, true
+}
+
+func (app *Application) getTeamTransferHandler(w http.ResponseWriter, r *http.Request) {
+	_, teamTransfer, ok := app.readTeamTransfer(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"team_transfer": teamTransfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) acceptTeamTransferHandler(w http.ResponseWriter, r *http.Request) {
+	team, teamTransfer, ok := app.readTeamTransfer(w, r)
+	if !ok {
+		return
+	}
+
+	// Only the nominated member can accept the transfer
+	user := app.contextGetUser(r)
+	if user.ID != teamTransfer.TeamTransferTo {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// Swap the owner, the old owner becomes a member
+	err := app.Models.TeamTransfers.Accept(r.Context(), teamTransfer, team)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) cancelTeamTransferHandler(w http.ResponseWriter, r *http.Request) {
+	_, teamTransfer, ok := app.readTeamTransfer(w, r)
+	if !ok {
+		return
+	}
+
+	// The owner withdraws the nomination, or the nominated member declines it
+	user := app.contextGetUser(r)
+	err := app.Models.TeamTransfers.Cancel(r.Context(), teamTransfer, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team_transfer": teamTransfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
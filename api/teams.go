@@ -3,108 +3,58 @@ package api
 import (
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
+	"github.com/e-inwork-com/go-team-service/internal/storage"
 	"github.com/e-inwork-com/go-team-service/internal/validator"
 )
 
 func (app *Application) createTeamHandler(w http.ResponseWriter, r *http.Request) {
-	// Get a name
-	teamName := r.FormValue("team_name")
-
-	// Read a file attachment
-	file, fileHeader, err := r.FormFile("team_picture")
-	if err == nil {
-		defer file.Close()
+	// Read the name and store the picture
+	form, ok := app.readTeamForm(w, r)
+	if !ok {
+		return
 	}
 
 	// Get the current user
 	user := app.contextGetUser(r)
 
-	// Set profile picture
-	teamPicture := ""
-	if file != nil {
-		teamPicture = fmt.Sprintf("%s%s", user.ID.String(), filepath.Ext(fileHeader.Filename))
-	}
-
 	// Set a Team
 	team := &data.Team{
 		TeamUser:    user.ID,
-		TeamName:    teamName,
-		TeamPicture: teamPicture,
+		TeamName:    form.TeamName,
+		TeamPicture: form.TeamPicture,
 	}
 
 	// Validate Profile
 	v := validator.New()
 	if data.ValidateTeam(v, team); !v.Valid() {
+		app.discardTeamPicture(r, form.TeamPicture)
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// Check type of file
-	if teamPicture != "" {
-		buff := make([]byte, 512)
-		_, err = file.Read(buff)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		filetype := http.DetectContentType(buff)
-		if filetype != "image/jpeg" && filetype != "image/png" {
-			http.Error(w, "Please upload a JPEG or PNG image", http.StatusBadRequest)
-			return
-		}
-
-		// Read from the beginning of the file offset
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		// Create an uploading folder if it doesn't
-		// already exist
-		err = os.MkdirAll(app.Config.Uploads, os.ModePerm)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		// Create a new file in the uploads directory
-		dst, err := os.Create(fmt.Sprintf("%s/%s", app.Config.Uploads, teamPicture))
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		defer dst.Close()
-
-		// Copy the uploaded file to the filesystem
-		// at the specified destination
-		_, err = io.Copy(dst, file)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-	}
-
 	// Insert data to Team
-	err = app.Models.Teams.Insert(app.Config.GRPCTeam, team)
+	err := app.Models.Teams.Insert(r.Context(), team)
 	if err != nil {
+		app.discardTeamPicture(r, form.TeamPicture)
+
 		switch {
+		case errors.Is(err, data.ErrCreateConflict), errors.Is(err, data.ErrInvalidReference):
+			app.constraintViolationResponse(w, r, err)
 		default:
-			app.errSQLResponse(w, r, err)
+			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
 	// Send a data as response of the HTTP request
-	err = app.writeJSON(w, http.StatusCreated, envelope{"team": team}, nil)
+	headers := teamHeaders(team)
+	headers.Set("Location", fmt.Sprintf("/service/teams/%s", team.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"team": team}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -115,7 +65,7 @@ func (app *Application) getOwnTeamHandler(w http.ResponseWriter, r *http.Request
 	user := app.contextGetUser(r)
 
 	// Get team by user
-	team, err := app.Models.Teams.GetByTeamUser(user.ID)
+	team, err := app.Models.Teams.GetByTeamUser(r.Context(), user.ID)
 
 	// Check error
 	if err != nil {
@@ -128,14 +78,14 @@ func (app *Application) getOwnTeamHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Send a request response
-	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, nil)
+	// Send a request response, the ETag lets the client update the version it read
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, teamHeaders(team))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *Application) patchTeamHandler(w http.ResponseWriter, r *http.Request) {
+func (app *Application) getTeamHandler(w http.ResponseWriter, r *http.Request) {
 	// Get ID from the request parameters
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -144,7 +94,7 @@ func (app *Application) patchTeamHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Get a record from the database
-	team, err := app.Models.Teams.GetByID(id)
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -155,108 +105,264 @@ func (app *Application) patchTeamHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Every role of the team can read it
+	if !app.authorizeTeam(w, r, actionReadTeam, team) {
+		return
+	}
+
+	// Send a request response, the ETag lets the client update the version it read
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, teamHeaders(team))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listTeamsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the current user
 	user := app.contextGetUser(r)
 
-	// Check if the record has a related to the current user
-	// Only the owner of the record can update the own record
-	if team.TeamUser != user.ID {
-		app.notPermittedResponse(w, r)
+	// Get the teams owned by the user or where the user is a member
+	teams, err := app.Models.Teams.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Get a profile name
-	teamName := r.FormValue("team_name")
+	// Send a request response
+	err = app.writeJSON(w, http.StatusOK, envelope{"teams": teams}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
 
-	// Read a file attachment
-	file, fileHeader, err := r.FormFile("team_picture")
-	if err == nil {
-		defer file.Close()
+func (app *Application) patchTeamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Get a record from the database
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the owner and the admins of the team can update it
+	if !app.authorizeTeam(w, r, actionUpdateTeam, team) {
+		return
+	}
+
+	// The team must not have changed since the client read it
+	if !app.ifMatch(w, r, teamETag(team)) {
+		return
 	}
 
-	// Set picture
-	teamPicture := ""
-	if file != nil {
-		teamPicture = fmt.Sprintf("%s%s", user.ID.String(), filepath.Ext(fileHeader.Filename))
+	// Read the name and store the picture
+	form, ok := app.readTeamForm(w, r)
+	if !ok {
+		return
 	}
 
 	// Set a new Profile
 	newTeam := &data.Team{
-		TeamName:    teamName,
-		TeamPicture: teamPicture,
+		TeamName:    form.TeamName,
+		TeamPicture: form.TeamPicture,
 	}
 
-	if teamPicture != "" {
-		// Check type of file
-		buff := make([]byte, 512)
-		_, err = file.Read(buff)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
+	// The uploaded picture is new when it isn't the current picture of the team,
+	// then it has to be deleted if the team can't be updated
+	newTeamPicture := newTeam.TeamPicture != "" && newTeam.TeamPicture != team.TeamPicture
+
+	// Validate the new name, the name is kept when it isn't sent
+	if newTeam.TeamName != "" {
+		v := validator.New()
+		if data.ValidateTeam(v, newTeam); !v.Valid() {
+			if newTeamPicture {
+				app.discardTeamPicture(r, newTeam.TeamPicture)
+			}
+			app.failedValidationResponse(w, r, v.Errors)
 			return
 		}
+	}
 
-		filetype := http.DetectContentType(buff)
-		if filetype != "image/jpeg" && filetype != "image/png" {
-			http.Error(w, "Please upload a JPEG or PNG image", http.StatusBadRequest)
-			return
+	// Update the old profile picture with a new one
+	if newTeam.TeamName != "" {
+		team.TeamName = newTeam.TeamName
+	}
+
+	oldTeamPicture := ""
+	if newTeamPicture {
+		oldTeamPicture = team.TeamPicture
+		team.TeamPicture = newTeam.TeamPicture
+	}
+
+	// Update the Profile
+	err = app.Models.Teams.Update(r.Context(), team)
+	if err != nil {
+		if newTeamPicture {
+			app.discardTeamPicture(r, newTeam.TeamPicture)
 		}
 
-		// Read a file from the beginning offset
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.teamChangedResponse(w, r, team.ID)
+		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
+		return
+	}
 
-		// Create an uploading folder if it doesn't
-		// already exist
-		err = os.MkdirAll(app.Config.Uploads, os.ModePerm)
+	// Delete the old profile picture once the team refers to the new one,
+	// the update succeeded so a failure is only logged
+	if oldTeamPicture != "" {
+		err = app.deleteTeamPicture(r.Context(), oldTeamPicture)
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
+			app.logError(r, err)
 		}
+	}
 
-		// Delete the old profile picture
-		if _, err := os.Stat(fmt.Sprintf("%s/%s", app.Config.Uploads, team.TeamPicture)); err == nil {
-			err = os.Remove(fmt.Sprintf("%s/%s", app.Config.Uploads, team.TeamPicture))
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	// Send back the record to the request response
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, teamHeaders(team))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteTeamPictureHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Get a record from the database
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
 		}
+		return
+	}
 
-		// Create a new file in the uploads directory
-		dst, err := os.Create(fmt.Sprintf("%s/%s", app.Config.Uploads, teamPicture))
-		if err != nil {
+	// Only the owner and the admins of the team can update it
+	if !app.authorizeTeam(w, r, actionUpdateTeam, team) {
+		return
+	}
+
+	// The team must not have changed since the client read it
+	if !app.ifMatch(w, r, teamETag(team)) {
+		return
+	}
+
+	// There is no picture to remove
+	if team.TeamPicture == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Clear the picture of the team
+	oldTeamPicture := team.TeamPicture
+	team.TeamPicture = ""
+
+	err = app.Models.Teams.Update(r.Context(), team)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.teamChangedResponse(w, r, team.ID)
+		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
-		defer dst.Close()
+		return
+	}
 
-		// Copy the uploaded file to the filesystem
-		// at the specified destination
-		_, err = io.Copy(dst, file)
-		if err != nil {
+	// Delete the picture, a file left behind is removed by the sweeper
+	err = app.deleteTeamPicture(r.Context(), oldTeamPicture)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	// Send back the record to the request response
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, teamHeaders(team))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteTeamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Get a record from the database
+	team, err := app.Models.Teams.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
+		return
 	}
 
-	// Update the old profile picture with a new one
-	if newTeam.TeamName != "" {
-		team.TeamName = newTeam.TeamName
+	// Only the owner of the team can delete it
+	if !app.authorizeTeam(w, r, actionDeleteTeam, team) {
+		return
 	}
 
-	if newTeam.TeamPicture != "" {
-		team.TeamPicture = newTeam.TeamPicture
+	// The team must not have changed since the client read it
+	if !app.ifMatch(w, r, teamETag(team)) {
+		return
 	}
 
-	// Update the Profile
-	err = app.Models.Teams.Update(app.Config.GRPCTeam, team)
+	// Soft delete the Team
+	err = app.Models.Teams.Delete(r.Context(), team)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
+			app.teamChangedResponse(w, r, team.ID)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Send a request response
+	err = app.writeJSON(w, http.StatusOK, nil, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) restoreTeamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get ID from the request parameters
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Restore a deleted Team
+	team, err := app.Models.Teams.Restore(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -278,16 +384,58 @@ func (app *Application) getProfilePictureHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Read file
-	buffer, err := os.ReadFile(fmt.Sprintf("%s/%s", app.Config.Uploads, file))
+	// Only serve the pictures of the teams
+	referenced, err := app.Models.Teams.PictureReferenced(r.Context(), file, false)
 	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !referenced {
 		app.notFoundResponse(w, r)
 		return
 	}
 
-	// Check type of file
-	filetype := http.DetectContentType(buffer)
+	// Get the size of a thumbnail
+	v := validator.New()
+
+	size := app.readInt(r.URL.Query(), "size", 0, v)
+	v.Check(size == 0 || picture.ValidSize(size), "size", "must be 64, 256 or 1024")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Read file, a picture uploaded before the thumbnails
+	// were generated is only stored in its original size
+	name := picture.VariantName(file, size)
+
+	blob, err := app.Storage.Get(r.Context(), name)
+	if errors.Is(err, storage.ErrNotFound) && size != 0 {
+		name = file
+		blob, err = app.Storage.Get(r.Context(), name)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+
+	// The type of file follows the validated name, it's never
+	// sniffed by the browser from the content
+	w.Header().Set("Content-Type", picture.ContentType(name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// A stored picture never changes, a new picture gets a new name
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+name+`"`)
 
-	w.Header().Set("Content-Type", filetype)
-	w.Write(buffer)
+	// Handle the conditional and the range requests
+	http.ServeContent(w, r, name, blob.ModTime, blob)
 }
@@ -1 +1,3 @@
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+DELETE FROM team_events;
+DELETE FROM team_audit_logs;
\ No newline at end of file
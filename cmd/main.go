@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"runtime"
 	"strings"
@@ -12,8 +15,12 @@ import (
 
 	"github.com/e-inwork-com/go-team-service/api"
 	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
 	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/e-inwork-com/go-team-service/internal/mailer"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
 	_ "github.com/lib/pq"
 )
@@ -31,16 +38,65 @@ func main() {
 	// Read environment variables
 	flag.IntVar(&cfg.Port, "port", 4002, "API server port")
 	flag.StringVar(&cfg.Env, "env", "development", "Environment (development|staging|production)")
+	flag.TextVar(&cfg.Log.Level, "log-level", jsonlog.LevelInfo, "Minimum level of the logs (debug|info|warn|error|fatal|off)")
+	flag.BoolVar(&cfg.Log.StackTraces, "log-stack-traces", false, "Add the stack trace to the error logs")
 	flag.StringVar(&cfg.Db.Dsn, "db-dsn", os.Getenv("DBDSN"), "Database DSN")
 	flag.StringVar(&cfg.Auth.Secret, "auth-secret", os.Getenv("AUTHSECRET"), "Authentication Secret")
+	flag.Func("admin-users", "IDs of the admin users (space separated)", func(val string) error {
+		for _, field := range strings.Fields(val) {
+			id, err := uuid.Parse(field)
+			if err != nil {
+				return err
+			}
+			cfg.Admin.Users = append(cfg.Admin.Users, id)
+		}
+		return nil
+	})
 	flag.IntVar(&cfg.Db.MaxOpenConn, "db-max-open-conn", 25, "Database max open connections")
 	flag.IntVar(&cfg.Db.MaxIdleConn, "db-max-idle-conn", 25, "Database max idle connections")
 	flag.StringVar(&cfg.Db.MaxIdleTime, "db-max-idle-time", "15m", "Database max connection idle time")
+	flag.DurationVar(&cfg.Db.QueryTimeout, "db-query-timeout", 3*time.Second, "Database query timeout, shortened by the deadline of the request")
 	flag.BoolVar(&cfg.Limiter.Enabled, "limiter-enabled", true, "Enable rate limiter")
 	flag.Float64Var(&cfg.Limiter.Rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.Limiter.Burst, "limiter-burst", 4, "Rate limiter maximum burst")
-	flag.StringVar(&cfg.Uploads, "uploads", os.Getenv("UPLOADS"), "Uploads folder")
+	flag.StringVar(&cfg.Storage.Driver, "storage", "filesystem", "Storage of the uploaded files (filesystem|s3|memory)")
+	flag.StringVar(&cfg.Uploads, "uploads", os.Getenv("UPLOADS"), "Uploads folder of the filesystem storage")
+	flag.IntVar(&cfg.Picture.MaxWidth, "picture-max-width", 4096, "Maximum width of an uploaded picture")
+	flag.IntVar(&cfg.Picture.MaxHeight, "picture-max-height", 4096, "Maximum height of an uploaded picture")
+	flag.Int64Var(&cfg.Picture.MaxUploadSize, "picture-max-upload-size", 10_485_760, "Maximum size in bytes of a team form with its picture")
+	flag.StringVar(&cfg.Storage.S3.Endpoint, "s3-endpoint", os.Getenv("S3ENDPOINT"), "S3 endpoint (host:port)")
+	flag.StringVar(&cfg.Storage.S3.Region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.Storage.S3.Bucket, "s3-bucket", os.Getenv("S3BUCKET"), "S3 bucket")
+	flag.StringVar(&cfg.Storage.S3.AccessKey, "s3-access-key", os.Getenv("S3ACCESSKEY"), "S3 access key")
+	flag.StringVar(&cfg.Storage.S3.SecretKey, "s3-secret-key", os.Getenv("S3SECRETKEY"), "S3 secret key")
+	flag.BoolVar(&cfg.Storage.S3.UseSSL, "s3-use-ssl", true, "Use HTTPS to connect to S3")
 	flag.StringVar(&cfg.GRPCTeam, "grpc-team", os.Getenv("GRPCTEAM"), "gRPC Teams")
+	flag.DurationVar(&cfg.GRPCTeamTimeout, "grpc-team-timeout", 3*time.Second, "gRPC Teams request timeout")
+	flag.StringVar(&cfg.Tracing.Exporter, "tracing-exporter", "none", "Exporter of the traces (otlp|stdout|none)")
+	flag.StringVar(&cfg.Tracing.Endpoint, "otlp-endpoint", os.Getenv("OTLPENDPOINT"), "OTLP/HTTP collector endpoint (host:port)")
+	flag.BoolVar(&cfg.Tracing.Insecure, "otlp-insecure", false, "Use HTTP to connect to the OTLP collector")
+	flag.Float64Var(&cfg.Tracing.SampleRatio, "tracing-sample-ratio", 1, "Ratio of the traces started by the service which are sampled")
+	flag.DurationVar(&cfg.Outbox.Interval, "outbox-interval", time.Second, "Interval of the team indexing events dispatcher")
+	flag.IntVar(&cfg.Outbox.BatchSize, "outbox-batch-size", 100, "Team indexing events delivered per batch")
+	flag.IntVar(&cfg.Outbox.MaxAttempts, "outbox-max-attempts", 20, "Maximum delivery attempts of a team indexing event")
+	flag.DurationVar(&cfg.Reconciler.Interval, "reconcile-interval", time.Minute, "Interval of the unindexed teams reconciler")
+	flag.IntVar(&cfg.Reconciler.BatchSize, "reconcile-batch-size", 100, "Teams re-indexed per reconciler run")
+	flag.IntVar(&cfg.Reconciler.Concurrency, "reconcile-concurrency", 4, "Concurrent indexing requests of the reconciler")
+	flag.DurationVar(&cfg.Purge.Interval, "purge-interval", time.Hour, "Interval of the deleted teams purge job")
+	flag.DurationVar(&cfg.Purge.Retention, "purge-retention", 30*24*time.Hour, "Retention of the deleted teams before they are purged")
+	flag.IntVar(&cfg.Purge.BatchSize, "purge-batch-size", 100, "Teams purged per run")
+	flag.DurationVar(&cfg.Sweeper.Interval, "sweep-interval", time.Hour, "Interval of the unreferenced pictures sweeper")
+	flag.DurationVar(&cfg.Sweeper.GracePeriod, "sweep-grace-period", 24*time.Hour, "Age of an unreferenced picture before it is deleted")
+	flag.BoolVar(&cfg.Concurrency.RequireIfMatch, "require-if-match", false, "Reject the team updates without an If-Match header")
+	flag.DurationVar(&cfg.Idempotency.TTL, "idempotency-ttl", 24*time.Hour, "How long the response of a request with an Idempotency-Key is replayed")
+	flag.DurationVar(&cfg.Health.Timeout, "health-timeout", 2*time.Second, "Timeout of each dependency check of the readiness probe")
+	flag.StringVar(&cfg.SMTP.Host, "smtp-host", os.Getenv("SMTPHOST"), "SMTP host, required outside of development where the emails are only logged if empty")
+	flag.IntVar(&cfg.SMTP.Port, "smtp-port", 25, "SMTP port")
+	flag.StringVar(&cfg.SMTP.Username, "smtp-username", os.Getenv("SMTPUSERNAME"), "SMTP username")
+	flag.StringVar(&cfg.SMTP.Password, "smtp-password", os.Getenv("SMTPPASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.SMTP.Sender, "smtp-sender", "e-inwork.com <no-reply@e-inwork.com>", "SMTP sender")
+	flag.DurationVar(&cfg.Invitation.TTL, "invitation-ttl", 7*24*time.Hour, "Expiry of the team invitations")
+	flag.StringVar(&cfg.Invitation.URL, "invitation-url", "http://localhost:8000/invitations", "Page where the invitee accepts a team invitation")
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.Cors.TrustedOrigins = strings.Fields(val)
 		return nil
@@ -55,8 +111,11 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Set logger
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	// Set logger, it also writes the logs of the standard library slog
+	logger := jsonlog.New(os.Stdout, cfg.Log.Level)
+	logger.SetStackTraces(cfg.Log.StackTraces)
+
+	slog.SetDefault(slog.New(jsonlog.NewHandler(logger)))
 
 	// Set Database
 	db, err := api.OpenDB(cfg)
@@ -68,6 +127,26 @@ func main() {
 	// Log a status of the database
 	logger.PrintInfo("database connection pool established", nil)
 
+	// Set gRPC connection to the Go Team Indexing Service
+	grpcTeam, err := api.OpenGRPCTeam(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	defer grpcTeam.Close()
+
+	// Set storage of the uploaded files
+	store, err := api.OpenStorage(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	// Set tracing
+	shutdownTracing, err := api.OpenTracing(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Publish variables
 	expvar.NewString("version").Set(api.Version)
 	expvar.Publish("goroutines", expvar.Func(func() interface{} {
@@ -80,11 +159,31 @@ func main() {
 		return time.Now().Unix()
 	}))
 
+	// Set mailer
+	var mail mailer.Mailer
+	if cfg.SMTP.Host != "" {
+		mail = mailer.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Sender)
+	} else {
+		// The invitations would never reach the users
+		if cfg.Env != "development" {
+			logger.PrintFatal(errors.New("smtp host is required outside of development"), nil)
+		}
+
+		logger.PrintInfo("smtp host is not set, emails are only logged", nil)
+		mail = mailer.NewLog(logger)
+	}
+
 	// Set the application
 	app := &api.Application{
 		Config: cfg,
 		Logger: logger,
-		Models: data.InitModels(db),
+		DB:     db,
+		Models: data.InitModels(db, cfg.Db.QueryTimeout),
+
+		TeamIndexing:   teams.NewTeamServiceClient(grpcTeam),
+		IndexingHealth: grpc_health_v1.NewHealthClient(grpcTeam),
+		Mailer:         mail,
+		Storage:        store,
 	}
 
 	// Run the application
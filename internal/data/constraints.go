@@ -0,0 +1,71 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// The codes of the Postgres errors of a violated constraint
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+	pqCheckViolation      = "23514"
+)
+
+// constraintFields are the fields of a record checked by a constraint,
+// the Postgres error only names the constraint
+var constraintFields = map[string]string{
+	"teams_team_user_fkey":                               "team_user",
+	"team_members_team_member_team_fkey":                 "team_member_team",
+	"team_members_team_member_user_fkey":                 "team_member_user",
+	"team_members_team_member_team_team_member_user_key": "team_member_user",
+	"team_members_role_check":                            "team_member_role",
+}
+
+// ConstraintError is a record which violates a constraint of the database,
+// it wraps ErrCreateConflict, ErrInvalidReference or ErrInvalidValue
+type ConstraintError struct {
+	Err        error
+	Field      string
+	Constraint string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s violates %s", e.Err, e.Field, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// constraintError returns a ConstraintError of a violated constraint,
+// any other error is returned as it is
+func constraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	var target error
+
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		target = ErrCreateConflict
+	case pqForeignKeyViolation:
+		target = ErrInvalidReference
+	case pqCheckViolation:
+		target = ErrInvalidValue
+	default:
+		return err
+	}
+
+	// A constraint without a known field is reported on the column
+	field, ok := constraintFields[pqErr.Constraint]
+	if !ok {
+		field = pqErr.Column
+	}
+
+	return &ConstraintError{Err: target, Field: field, Constraint: pqErr.Constraint}
+}
@@ -0,0 +1,145 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Filters struct {
+	Page         int
+	PageSize     int
+	Sort         string
+	SortSafelist []string
+	Cursor       string
+}
+
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if f.Cursor != "" {
+		_, err := f.cursor()
+		v.Check(err == nil, "cursor", "invalid cursor")
+	}
+}
+
+// sortColumn returns the column of the sort value, the sort value
+// is checked against the safelist before it goes into a query
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// cursorOperator returns the comparison of the rows after the cursor
+func (f Filters) cursorOperator() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "<"
+	}
+
+	return ">"
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+// offset is only used by page based pagination,
+// a cursor starts from the row after the cursor
+func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
+
+	return (f.Page - 1) * f.PageSize
+}
+
+// cursor is the position of the last row of a page,
+// encoded as base64 JSON in the next_cursor metadata
+type cursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// cursor decodes the cursor, a cursor is only valid with the sort it was created with
+func (f Filters) cursor() (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.Sort != f.Sort || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+type Metadata struct {
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+}
+
+func calculateMetadata(totalRecords int, f Filters, nextCursor string) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	metadata := Metadata{
+		PageSize:     f.PageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(f.PageSize))),
+		TotalRecords: totalRecords,
+		NextCursor:   nextCursor,
+	}
+
+	// The page number is unknown when paginating by cursor
+	if f.Cursor == "" {
+		metadata.CurrentPage = f.Page
+	}
+
+	return metadata
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IdempotencyKeyModelInterface interface {
+	Claim(ctx context.Context, key *IdempotencyKey, ttl time.Duration, lease time.Duration) (bool, error)
+	Complete(ctx context.Context, key *IdempotencyKey) error
+	Release(ctx context.Context, key *IdempotencyKey) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// IdempotencyKey is the Idempotency-Key of a request of a user, with the
+// fingerprint of the request and its response once it is handled
+type IdempotencyKey struct {
+	Key             string
+	User            uuid.UUID
+	ExpiresAt       time.Time
+	RequestHash     []byte
+	ResponseStatus  int
+	ResponseHeaders map[string][]string
+	ResponseBody    []byte
+}
+
+// Handled reports whether the response of the request is stored
+func (k *IdempotencyKey) Handled() bool {
+	return k.ResponseStatus != 0
+}
+
+type IdempotencyKeyModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Claim records the key for the request about to be handled and reports true,
+// unless the key is already recorded, then the key is filled in with the stored
+// request. An expired key, or a key whose request didn't complete within
+// the lease, is claimed again.
+func (m IdempotencyKeyModel) Claim(ctx context.Context, key *IdempotencyKey, ttl time.Duration, lease time.Duration) (bool, error) {
+	query := `
+        INSERT INTO idempotency_keys (idempotency_key, idempotency_user, expires_at)
+        VALUES ($1, $2, NOW() + make_interval(secs => $3))
+        ON CONFLICT (idempotency_user, idempotency_key) DO UPDATE
+        SET created_at = NOW(), expires_at = EXCLUDED.expires_at, request_hash = NULL,
+            response_status = NULL, response_headers = NULL, response_body = NULL
+        WHERE idempotency_keys.expires_at <= NOW()
+        OR (idempotency_keys.response_status IS NULL AND idempotency_keys.created_at <= NOW() - make_interval(secs => $4))
+        RETURNING expires_at`
+
+	args := []interface{}{key.Key, key.User, ttl.Seconds(), lease.Seconds()}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "IdempotencyKeyModel.Claim")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ExpiresAt)
+	if err == nil {
+		return true, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	// The key is used by another request
+	query = `
+        SELECT expires_at, COALESCE(request_hash, ''), COALESCE(response_status, 0),
+            COALESCE(response_headers, '{}'), COALESCE(response_body, '')
+        FROM idempotency_keys
+        WHERE idempotency_user = $1 AND idempotency_key = $2`
+
+	var headers []byte
+
+	err = m.DB.QueryRowContext(ctx, query, key.User, key.Key).Scan(
+		&key.ExpiresAt,
+		&key.RequestHash,
+		&key.ResponseStatus,
+		&headers,
+		&key.ResponseBody,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	err = json.Unmarshal(headers, &key.ResponseHeaders)
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (m IdempotencyKeyModel) Complete(ctx context.Context, key *IdempotencyKey) error {
+	query := `
+        UPDATE idempotency_keys
+        SET request_hash = $1, response_status = $2, response_headers = $3, response_body = $4
+        WHERE idempotency_user = $5 AND idempotency_key = $6`
+
+	headers, err := json.Marshal(key.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{
+		key.RequestHash,
+		key.ResponseStatus,
+		headers,
+		key.ResponseBody,
+		key.User,
+		key.Key,
+	}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "IdempotencyKeyModel.Complete")
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Release deletes a claimed key whose request failed, so the client can retry it
+func (m IdempotencyKeyModel) Release(ctx context.Context, key *IdempotencyKey) error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE idempotency_user = $1 AND idempotency_key = $2 AND response_status IS NULL`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "IdempotencyKeyModel.Release")
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key.User, key.Key)
+	return err
+}
+
+func (m IdempotencyKeyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE expires_at <= NOW()`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "IdempotencyKeyModel.DeleteExpired")
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
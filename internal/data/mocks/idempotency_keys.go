@@ -0,0 +1,70 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+)
+
+// IdempotencyKeyModel keeps the keys in memory, so a request can be replayed
+type IdempotencyKeyModel struct {
+	mu   sync.Mutex
+	keys map[string]data.IdempotencyKey
+}
+
+func (m *IdempotencyKeyModel) Claim(ctx context.Context, key *data.IdempotencyKey, ttl time.Duration, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keys == nil {
+		m.keys = make(map[string]data.IdempotencyKey)
+	}
+
+	id := key.User.String() + "/" + key.Key
+
+	stored, ok := m.keys[id]
+	if ok && stored.ExpiresAt.After(time.Now()) {
+		*key = stored
+		return false, nil
+	}
+
+	key.ExpiresAt = time.Now().Add(ttl)
+	m.keys[id] = *key
+
+	return true, nil
+}
+
+func (m *IdempotencyKeyModel) Complete(ctx context.Context, key *data.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.User.String()+"/"+key.Key] = *key
+
+	return nil
+}
+
+func (m *IdempotencyKeyModel) Release(ctx context.Context, key *data.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key.User.String()+"/"+key.Key)
+
+	return nil
+}
+
+func (m *IdempotencyKeyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for id, key := range m.keys {
+		if !key.ExpiresAt.After(time.Now()) {
+			delete(m.keys, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
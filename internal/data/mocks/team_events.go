@@ -0,0 +1,22 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+)
+
+type TeamEventModel struct{}
+
+func (m TeamEventModel) Claim(ctx context.Context, limit int, maxAttempts int, lease time.Duration) ([]*data.TeamEvent, error) {
+	return []*data.TeamEvent{}, nil
+}
+
+func (m TeamEventModel) MarkDelivered(ctx context.Context, event *data.TeamEvent) error {
+	return nil
+}
+
+func (m TeamEventModel) MarkFailed(ctx context.Context, event *data.TeamEvent, reason error, retryAt time.Time) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/google/uuid"
+)
+
+type TeamInvitationModel struct{}
+
+func (m TeamInvitationModel) Insert(ctx context.Context, teamInvitation *data.TeamInvitation) error {
+	teamInvitation.ID = MockFirstUUID()
+	teamInvitation.CreatedAt = time.Now()
+	teamInvitation.TeamInvitationStatus = data.InvitationPending
+
+	return nil
+}
+
+func (m TeamInvitationModel) GetByToken(ctx context.Context, token string) (*data.TeamInvitation, error) {
+	if token == MockInvitationToken() {
+		var teamInvitation = &data.TeamInvitation{
+			ID:                     MockFirstUUID(),
+			CreatedAt:              time.Now(),
+			TeamInvitationTeam:     MockFirstUUID(),
+			TeamInvitationTeamName: "Doe's Team",
+			TeamInvitationInviter:  MockFirstUUID(),
+			TeamInvitationEmail:    "nina@doe.com",
+			TeamInvitationRole:     data.RoleMember,
+			TeamInvitationStatus:   data.InvitationPending,
+			TeamInvitationExpiry:   time.Now().Add(time.Hour),
+		}
+
+		return teamInvitation, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m TeamInvitationModel) ListByEmail(ctx context.Context, email string) ([]*data.TeamInvitation, error) {
+	teamInvitations := []*data.TeamInvitation{}
+
+	if strings.EqualFold(email, "nina@doe.com") {
+		teamInvitation, _ := m.GetByToken(ctx, MockInvitationToken())
+		teamInvitations = append(teamInvitations, teamInvitation)
+	}
+
+	return teamInvitations, nil
+}
+
+func (m TeamInvitationModel) Accept(ctx context.Context, teamInvitation *data.TeamInvitation, user uuid.UUID) error {
+	teamInvitation.TeamInvitationStatus = data.InvitationAccepted
+
+	return nil
+}
+
+func (m TeamInvitationModel) Decline(ctx context.Context, teamInvitation *data.TeamInvitation) error {
+	teamInvitation.TeamInvitationStatus = data.InvitationDeclined
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
@@ -9,14 +10,23 @@ import (
 
 type TeamMemberModel struct{}
 
-func (m TeamMemberModel) Insert(teamMember *data.TeamMember) error {
+func (m TeamMemberModel) Insert(ctx context.Context, teamMember *data.TeamMember) error {
+	// The first user is already a member of the mock team
+	if teamMember.TeamMemberUser == MockFirstUUID() {
+		return &data.ConstraintError{
+			Err:        data.ErrCreateConflict,
+			Field:      "team_member_user",
+			Constraint: "team_members_team_member_team_team_member_user_key",
+		}
+	}
+
 	teamMember.ID = MockFirstUUID()
 	teamMember.CreatedAt = time.Now()
 
 	return nil
 }
 
-func (m TeamMemberModel) GetByID(id uuid.UUID) (*data.TeamMember, error) {
+func (m TeamMemberModel) GetByID(ctx context.Context, id uuid.UUID) (*data.TeamMember, error) {
 	teamMemberId := MockFirstUUID()
 
 	if id == teamMemberId {
@@ -28,6 +38,26 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*data.TeamMember, error) {
 			TeamMemberUser:          MockSecondUUID(),
 			TeamMemberUserFirstName: "Nina",
 			TeamMemberUserLastName:  "Doe",
+			TeamMemberRole:          data.RoleMember,
+		}
+
+		return teamMember, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m TeamMemberModel) GetByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) (*data.TeamMember, error) {
+	if teamMemberTeam == MockFirstUUID() && teamMemberUser == MockSecondUUID() {
+		var teamMember = &data.TeamMember{
+			ID:                      MockFirstUUID(),
+			CreatedAt:               time.Now(),
+			TeamMemberTeam:          teamMemberTeam,
+			TeamMemberTeamName:      "Doe's Team",
+			TeamMemberUser:          teamMemberUser,
+			TeamMemberUserFirstName: "Nina",
+			TeamMemberUserLastName:  "Doe",
+			TeamMemberRole:          data.RoleMember,
 		}
 
 		return teamMember, nil
@@ -36,7 +66,7 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*data.TeamMember, error) {
 	return nil, data.ErrRecordNotFound
 }
 
-func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*data.TeamMember, error) {
+func (m TeamMemberModel) ListByOwner(ctx context.Context, teamMemberTeam uuid.UUID) ([]*data.TeamMember, error) {
 	teamMemberTeamId := MockFirstUUID()
 
 	teamMembers := []*data.TeamMember{}
@@ -50,6 +80,7 @@ func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*data.TeamMemb
 			TeamMemberUser:          MockSecondUUID(),
 			TeamMemberUserFirstName: "Nina",
 			TeamMemberUserLastName:  "Doe",
+			TeamMemberRole:          data.RoleMember,
 		}
 
 		teamMembers = append(teamMembers, teamMember)
@@ -58,7 +89,29 @@ func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*data.TeamMemb
 	return teamMembers, nil
 }
 
-func (m TeamMemberModel) Delete(teamMember *data.TeamMember) error {
+func (m TeamMemberModel) ListByTeam(ctx context.Context, teamMemberTeam uuid.UUID, name string, roles []string, filters data.Filters) ([]*data.TeamMember, data.Metadata, error) {
+	teamMembers, _ := m.ListByOwner(ctx, teamMemberTeam)
+
+	metadata := data.Metadata{}
+	if len(teamMembers) > 0 {
+		metadata = data.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: len(teamMembers)}
+	}
+
+	return teamMembers, metadata, nil
+}
+
+func (m TeamMemberModel) ListByUser(ctx context.Context, teamMemberUser uuid.UUID) ([]*data.TeamMember, error) {
+	teamMembers := []*data.TeamMember{}
+
+	teamMember, err := m.GetByTeamAndUser(ctx, MockFirstUUID(), teamMemberUser)
+	if err == nil {
+		teamMembers = append(teamMembers, teamMember)
+	}
+
+	return teamMembers, nil
+}
+
+func (m TeamMemberModel) UpdateRole(ctx context.Context, teamMember *data.TeamMember) error {
 	id := MockFirstUUID()
 
 	if teamMember.ID != id {
@@ -67,3 +120,19 @@ func (m TeamMemberModel) Delete(teamMember *data.TeamMember) error {
 
 	return nil
 }
+
+func (m TeamMemberModel) Delete(ctx context.Context, teamMember *data.TeamMember) error {
+	id := MockFirstUUID()
+
+	if teamMember.ID != id {
+		return data.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m TeamMemberModel) DeleteByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) error {
+	_, err := m.GetByTeamAndUser(ctx, teamMemberTeam, teamMemberUser)
+
+	return err
+}
@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/data"
+	"github.com/google/uuid"
+)
+
+type TeamTransferModel struct{}
+
+func (m TeamTransferModel) Insert(ctx context.Context, teamTransfer *data.TeamTransfer) error {
+	teamTransfer.ID = MockFirstUUID()
+	teamTransfer.CreatedAt = time.Now()
+	teamTransfer.TeamTransferStatus = data.TransferPending
+
+	return nil
+}
+
+func (m TeamTransferModel) GetPendingByTeam(ctx context.Context, teamTransferTeam uuid.UUID) (*data.TeamTransfer, error) {
+	if teamTransferTeam == MockFirstUUID() {
+		var teamTransfer = &data.TeamTransfer{
+			ID:                 MockFirstUUID(),
+			CreatedAt:          time.Now(),
+			TeamTransferTeam:   teamTransferTeam,
+			TeamTransferFrom:   MockFirstUUID(),
+			TeamTransferTo:     MockSecondUUID(),
+			TeamTransferStatus: data.TransferPending,
+		}
+
+		return teamTransfer, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m TeamTransferModel) Accept(ctx context.Context, teamTransfer *data.TeamTransfer, team *data.Team) error {
+	teamTransfer.TeamTransferStatus = data.TransferAccepted
+	team.TeamUser = teamTransfer.TeamTransferTo
+	team.Version++
+	team.IndexVersion++
+
+	return nil
+}
+
+func (m TeamTransferModel) Cancel(ctx context.Context, teamTransfer *data.TeamTransfer, actor uuid.UUID) error {
+	teamTransfer.TeamTransferStatus = data.TransferCancelled
+
+	return nil
+}
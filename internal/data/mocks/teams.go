@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
@@ -9,25 +10,27 @@ import (
 
 type TeamModel struct{}
 
-func (m TeamModel) Insert(gRPCTeam string, team *data.Team) error {
+func (m TeamModel) Insert(ctx context.Context, team *data.Team) error {
 	team.ID = MockFirstUUID()
 	team.CreatedAt = time.Now()
 	team.Version = 1
+	team.IndexVersion = 1
 
 	return nil
 }
 
-func (m TeamModel) GetByID(id uuid.UUID) (*data.Team, error) {
+func (m TeamModel) GetByID(ctx context.Context, id uuid.UUID) (*data.Team, error) {
 	teamId := MockFirstUUID()
 
 	if teamId == id {
 		var team = &data.Team{
-			ID:          teamId,
-			CreatedAt:   time.Now(),
-			TeamUser:    teamId,
-			TeamName:    "Doe's Team",
-			TeamPicture: "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
-			Version:     1,
+			ID:           teamId,
+			CreatedAt:    time.Now(),
+			TeamUser:     teamId,
+			TeamName:     "Doe's Team",
+			TeamPicture:  "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
+			Version:      1,
+			IndexVersion: 1,
 		}
 
 		return team, nil
@@ -36,17 +39,18 @@ func (m TeamModel) GetByID(id uuid.UUID) (*data.Team, error) {
 	return nil, data.ErrRecordNotFound
 }
 
-func (m TeamModel) GetByTeamUser(teamUser uuid.UUID) (*data.Team, error) {
+func (m TeamModel) GetByTeamUser(ctx context.Context, teamUser uuid.UUID) (*data.Team, error) {
 	teamUserId := MockFirstUUID()
 
 	if teamUserId == teamUser {
 		var team = &data.Team{
-			ID:          teamUserId,
-			CreatedAt:   time.Now(),
-			TeamUser:    teamUserId,
-			TeamName:    "Doe's Team",
-			TeamPicture: "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
-			Version:     1,
+			ID:           teamUserId,
+			CreatedAt:    time.Now(),
+			TeamUser:     teamUserId,
+			TeamName:     "Doe's Team",
+			TeamPicture:  "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
+			Version:      1,
+			IndexVersion: 1,
 		}
 
 		return team, nil
@@ -55,8 +59,77 @@ func (m TeamModel) GetByTeamUser(teamUser uuid.UUID) (*data.Team, error) {
 	return nil, data.ErrRecordNotFound
 }
 
-func (m TeamModel) Update(gRPCTeam string, team *data.Team) error {
+func (m TeamModel) ListByUser(ctx context.Context, user uuid.UUID) ([]*data.Team, error) {
+	teams := []*data.Team{}
+
+	if user == MockFirstUUID() || user == MockSecondUUID() {
+		var team = &data.Team{
+			ID:           MockFirstUUID(),
+			CreatedAt:    time.Now(),
+			TeamUser:     MockFirstUUID(),
+			TeamName:     "Doe's Team",
+			TeamPicture:  "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
+			Version:      1,
+			IndexVersion: 1,
+		}
+
+		teams = append(teams, team)
+	}
+
+	return teams, nil
+}
+
+func (m TeamModel) Update(ctx context.Context, team *data.Team) error {
 	team.Version = team.Version + 1
+	team.IndexVersion = team.IndexVersion + 1
 
 	return nil
 }
+
+func (m TeamModel) ListUnindexed(ctx context.Context, limit int) ([]*data.Team, error) {
+	return []*data.Team{}, nil
+}
+
+func (m TeamModel) MarkIndexed(ctx context.Context, team *data.Team) error {
+	return nil
+}
+
+func (m TeamModel) Delete(ctx context.Context, team *data.Team) error {
+	team.Version = team.Version + 1
+	team.IndexVersion = team.IndexVersion + 1
+	team.IsDeleted = true
+
+	return nil
+}
+
+func (m TeamModel) Restore(ctx context.Context, id uuid.UUID) (*data.Team, error) {
+	teamId := MockFirstUUID()
+
+	if teamId == id {
+		var team = &data.Team{
+			ID:           teamId,
+			CreatedAt:    time.Now(),
+			TeamUser:     teamId,
+			TeamName:     "Doe's Team",
+			TeamPicture:  "77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
+			Version:      3,
+			IndexVersion: 3,
+		}
+
+		return team, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m TeamModel) ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]*data.Team, error) {
+	return []*data.Team{}, nil
+}
+
+func (m TeamModel) Purge(ctx context.Context, team *data.Team) error {
+	return nil
+}
+
+func (m TeamModel) PictureReferenced(ctx context.Context, teamPicture string, includeDeleted bool) (bool, error) {
+	return teamPicture == MockFirstUUID().String()+".jpg", nil
+}
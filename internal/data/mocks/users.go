@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/data"
@@ -9,7 +10,7 @@ import (
 
 type UserModel struct{}
 
-func (m UserModel) GetByID(id uuid.UUID) (*data.User, error) {
+func (m UserModel) GetByID(ctx context.Context, id uuid.UUID) (*data.User, error) {
 	if MockFirstUUID() == id {
 		var user = &data.User{
 			ID:        id,
@@ -11,3 +11,7 @@ func MockSecondUUID() uuid.UUID {
 	id, _ := uuid.Parse("77134e81-0cbe-4148-bb41-f0eecd56ac11")
 	return id
 }
+
+func MockInvitationToken() string {
+	return "MOCKINVITATIONTOKEN0000000"
+}
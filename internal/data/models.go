@@ -1,26 +1,63 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrCreateConflict = errors.New("create conflict")
-	ErrEditConflict   = errors.New("edit conflict")
+	ErrRecordNotFound   = errors.New("record not found")
+	ErrCreateConflict   = errors.New("create conflict")
+	ErrEditConflict     = errors.New("edit conflict")
+	ErrInvalidReference = errors.New("invalid reference")
+	ErrInvalidValue     = errors.New("invalid value")
 )
 
 type Models struct {
 	Teams       TeamModelInterface
 	Users       UserModelInterface
 	TeamMembers TeamMemberModelInterface
+	TeamEvents  TeamEventModelInterface
+
+	TeamInvitations TeamInvitationModelInterface
+	TeamTransfers   TeamTransferModelInterface
+	IdempotencyKeys IdempotencyKeyModelInterface
 }
 
-func InitModels(db *sql.DB) Models {
+// InitModels returns the models of the database, every query
+// is cancelled after the timeout or when its context is done
+func InitModels(db *sql.DB, timeout time.Duration) Models {
 	return Models{
-		Teams:       TeamModel{DB: db},
-		Users:       UserModel{DB: db},
-		TeamMembers: TeamMemberModel{DB: db},
+		Teams:       TeamModel{DB: db, Timeout: timeout},
+		Users:       UserModel{DB: db, Timeout: timeout},
+		TeamMembers: TeamMemberModel{DB: db, Timeout: timeout},
+		TeamEvents:  TeamEventModel{DB: db, Timeout: timeout},
+
+		TeamInvitations: TeamInvitationModel{DB: db, Timeout: timeout},
+		TeamTransfers:   TeamTransferModel{DB: db, Timeout: timeout},
+		IdempotencyKeys: IdempotencyKeyModel{DB: db, Timeout: timeout},
+	}
+}
+
+var tracer = otel.Tracer("github.com/e-inwork-com/go-team-service/internal/data")
+
+// queryContext returns the context of the queries of a model method, they are
+// cancelled after the timeout and traced in a span ended by the cancel function
+func queryContext(ctx context.Context, timeout time.Duration, name string) (context.Context, context.CancelFunc) {
+	ctx, span := tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, func() {
+		cancel()
+		span.End()
 	}
 }
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditTransferRequested = "ownership_transfer_requested"
+	AuditTransferAccepted  = "ownership_transfer_accepted"
+	AuditTransferCancelled = "ownership_transfer_cancelled"
+)
+
+// insertTeamAuditLog records an audit entry inside the transaction of the change
+func insertTeamAuditLog(ctx context.Context, tx *sql.Tx, team uuid.UUID, actor uuid.UUID, action string, details map[string]string) error {
+	js, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO team_audit_logs (team_id, actor, action, details)
+        VALUES ($1, $2, $3, $4)`
+
+	args := []interface{}{team, actor, action, js}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TeamEventUpsert = "upsert"
+	TeamEventDelete = "delete"
+)
+
+type TeamEventModelInterface interface {
+	Claim(ctx context.Context, limit int, maxAttempts int, lease time.Duration) ([]*TeamEvent, error)
+	MarkDelivered(ctx context.Context, event *TeamEvent) error
+	MarkFailed(ctx context.Context, event *TeamEvent, reason error, retryAt time.Time) error
+}
+
+// TeamEvent is an outbox record of a team write that still has to be
+// delivered to the Go Team Indexing Service
+type TeamEvent struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	TeamID      uuid.UUID `json:"team_id"`
+	TeamVersion int       `json:"team_version"` // the index version of the team
+	Operation   string    `json:"operation"`
+	Attempts    int       `json:"attempts"`
+}
+
+type TeamEventModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// insertTeamEvent records an event inside the transaction of the team write,
+// so the event exists if and only if the write is committed
+func insertTeamEvent(ctx context.Context, tx *sql.Tx, team *Team, operation string) error {
+	query := `
+        INSERT INTO team_events (team_id, team_version, operation)
+        VALUES ($1, $2, $3)`
+
+	args := []interface{}{team.ID, team.IndexVersion, operation}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// touchTeam bumps the index version of a team whose members changed, and
+// records the indexing event in the transaction of the change, because the
+// indexed document holds the members. The version of the team is left as is,
+// the team itself didn't change. A deleted team isn't indexed anymore.
+func touchTeam(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	query := `
+        UPDATE teams
+        SET index_version = index_version + 1, is_indexed = false, updated_at = NOW()
+        WHERE id = $1 AND is_deleted = false
+        RETURNING index_version`
+
+	team := &Team{ID: id}
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&team.IndexVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return insertTeamEvent(ctx, tx, team, TeamEventUpsert)
+}
+
+func (m TeamEventModel) Claim(ctx context.Context, limit int, maxAttempts int, lease time.Duration) ([]*TeamEvent, error) {
+	// Lock the pending events for the lease duration,
+	// so other replicas skip them while they are delivered
+	query := `
+        UPDATE team_events
+        SET next_attempt_at = NOW() + make_interval(secs => $3)
+        WHERE id IN (
+            SELECT id
+            FROM team_events
+            WHERE delivered_at IS NULL
+            AND next_attempt_at <= NOW()
+            AND attempts < $2
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED)
+        RETURNING id, created_at, team_id, team_version, operation, attempts`
+
+	args := []interface{}{limit, maxAttempts, lease.Seconds()}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamEventModel.Claim")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*TeamEvent{}
+
+	for rows.Next() {
+		var event TeamEvent
+
+		err = rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&event.TeamID,
+			&event.TeamVersion,
+			&event.Operation,
+			&event.Attempts,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (m TeamEventModel) MarkDelivered(ctx context.Context, event *TeamEvent) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamEventModel.MarkDelivered")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Close the event
+	query := `
+        UPDATE team_events
+        SET delivered_at = NOW(), attempts = attempts + 1, last_error = ''
+        WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, query, event.ID)
+	if err != nil {
+		return err
+	}
+
+	// Flag the team as indexed, unless it was changed
+	// again after the event was recorded
+	query = `
+        UPDATE teams
+        SET is_indexed = true
+        WHERE id = $1 AND index_version = $2`
+
+	_, err = tx.ExecContext(ctx, query, event.TeamID, event.TeamVersion)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamEventModel) MarkFailed(ctx context.Context, event *TeamEvent, reason error, retryAt time.Time) error {
+	query := `
+        UPDATE team_events
+        SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+        WHERE id = $3`
+
+	args := []interface{}{reason.Error(), retryAt, event.ID}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamEventModel.MarkFailed")
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
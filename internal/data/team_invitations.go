@@ -0,0 +1,322 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+type TeamInvitationModelInterface interface {
+	Insert(ctx context.Context, teamInvitation *TeamInvitation) error
+	GetByToken(ctx context.Context, token string) (*TeamInvitation, error)
+	ListByEmail(ctx context.Context, email string) ([]*TeamInvitation, error)
+	Accept(ctx context.Context, teamInvitation *TeamInvitation, user uuid.UUID) error
+	Decline(ctx context.Context, teamInvitation *TeamInvitation) error
+}
+
+type TeamInvitation struct {
+	ID                     uuid.UUID `json:"id"`
+	CreatedAt              time.Time `json:"created_at"`
+	TeamInvitationTeam     uuid.UUID `json:"team_invitation_team"`
+	TeamInvitationTeamName string    `json:"team_invitation_team_name"`
+	TeamInvitationInviter  uuid.UUID `json:"team_invitation_inviter"`
+	TeamInvitationEmail    string    `json:"team_invitation_email"`
+	TeamInvitationRole     string    `json:"team_invitation_role"`
+	TeamInvitationStatus   string    `json:"team_invitation_status"`
+	TeamInvitationExpiry   time.Time `json:"team_invitation_expiry"`
+	Token                  string    `json:"-"`
+	Hash                   []byte    `json:"-"`
+}
+
+func ValidateTeamInvitation(v *validator.Validator, teamInvitation *TeamInvitation) {
+	v.Check(teamInvitation.TeamInvitationEmail != "", "team_invitation_email", "must be provided")
+	v.Check(validator.Matches(teamInvitation.TeamInvitationEmail, validator.EmailRX), "team_invitation_email", "must be a valid email address")
+	v.Check(validator.In(teamInvitation.TeamInvitationRole, RoleAdmin, RoleMember, RoleViewer), "team_invitation_role", "must be admin, member or viewer")
+}
+
+func ValidateTeamInvitationToken(v *validator.Validator, token string) {
+	v.Check(token != "", "token", "must be provided")
+	v.Check(len(token) == 26, "token", "must be 26 bytes long")
+}
+
+// GenerateTeamInvitationToken sets a new single-use token on the invitation,
+// only the hash of the token is stored in the database
+func GenerateTeamInvitationToken(teamInvitation *TeamInvitation, ttl time.Duration) error {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	teamInvitation.Token = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(teamInvitation.Token))
+	teamInvitation.Hash = hash[:]
+	teamInvitation.TeamInvitationExpiry = time.Now().Add(ttl)
+
+	return nil
+}
+
+type TeamInvitationModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m TeamInvitationModel) Insert(ctx context.Context, teamInvitation *TeamInvitation) error {
+	// Inviting the same email again replaces the pending invitation
+	query := `
+        INSERT INTO team_invitations (
+            team_invitation_team,
+            team_invitation_inviter,
+            team_invitation_email,
+            team_invitation_role,
+            team_invitation_expiry,
+            token_hash)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (team_invitation_team, team_invitation_email) WHERE team_invitation_status = 'pending'
+        DO UPDATE SET
+            created_at = NOW(),
+            team_invitation_inviter = EXCLUDED.team_invitation_inviter,
+            team_invitation_role = EXCLUDED.team_invitation_role,
+            team_invitation_expiry = EXCLUDED.team_invitation_expiry,
+            token_hash = EXCLUDED.token_hash
+        RETURNING id, created_at, team_invitation_status`
+
+	teamInvitation.TeamInvitationEmail = strings.ToLower(teamInvitation.TeamInvitationEmail)
+
+	args := []interface{}{
+		teamInvitation.TeamInvitationTeam,
+		teamInvitation.TeamInvitationInviter,
+		teamInvitation.TeamInvitationEmail,
+		teamInvitation.TeamInvitationRole,
+		teamInvitation.TeamInvitationExpiry,
+		teamInvitation.Hash,
+	}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamInvitationModel.Insert")
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&teamInvitation.ID,
+		&teamInvitation.CreatedAt,
+		&teamInvitation.TeamInvitationStatus,
+	)
+}
+
+func (m TeamInvitationModel) GetByToken(ctx context.Context, token string) (*TeamInvitation, error) {
+	query := `
+    SELECT
+			team_invitations.id,
+			team_invitations.created_at,
+			team_invitation_team,
+			teams.team_name as team_invitation_team_name,
+			team_invitation_inviter,
+			team_invitation_email,
+			team_invitation_role,
+			team_invitation_status,
+			team_invitation_expiry
+    FROM team_invitations, teams
+		WHERE token_hash = $1
+		AND team_invitation_status = 'pending'
+		AND team_invitation_expiry > NOW()
+		AND team_invitation_team = teams.id
+		AND teams.is_deleted = false
+	`
+
+	hash := sha256.Sum256([]byte(token))
+
+	var teamInvitation TeamInvitation
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamInvitationModel.GetByToken")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&teamInvitation.ID,
+		&teamInvitation.CreatedAt,
+		&teamInvitation.TeamInvitationTeam,
+		&teamInvitation.TeamInvitationTeamName,
+		&teamInvitation.TeamInvitationInviter,
+		&teamInvitation.TeamInvitationEmail,
+		&teamInvitation.TeamInvitationRole,
+		&teamInvitation.TeamInvitationStatus,
+		&teamInvitation.TeamInvitationExpiry,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &teamInvitation, nil
+}
+
+func (m TeamInvitationModel) ListByEmail(ctx context.Context, email string) ([]*TeamInvitation, error) {
+	// The invitations are matched by email, so an invitation sent before
+	// the user signed up is found as soon as the user is registered
+	query := `
+    SELECT
+			team_invitations.id,
+			team_invitations.created_at,
+			team_invitation_team,
+			teams.team_name as team_invitation_team_name,
+			team_invitation_inviter,
+			team_invitation_email,
+			team_invitation_role,
+			team_invitation_status,
+			team_invitation_expiry
+    FROM team_invitations, teams
+		WHERE team_invitation_email = $1
+		AND team_invitation_status = 'pending'
+		AND team_invitation_expiry > NOW()
+		AND team_invitation_team = teams.id
+		AND teams.is_deleted = false
+		ORDER BY team_invitations.created_at DESC
+	`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamInvitationModel.ListByEmail")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teamInvitations := []*TeamInvitation{}
+
+	for rows.Next() {
+		var teamInvitation TeamInvitation
+
+		err = rows.Scan(
+			&teamInvitation.ID,
+			&teamInvitation.CreatedAt,
+			&teamInvitation.TeamInvitationTeam,
+			&teamInvitation.TeamInvitationTeamName,
+			&teamInvitation.TeamInvitationInviter,
+			&teamInvitation.TeamInvitationEmail,
+			&teamInvitation.TeamInvitationRole,
+			&teamInvitation.TeamInvitationStatus,
+			&teamInvitation.TeamInvitationExpiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		teamInvitations = append(teamInvitations, &teamInvitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teamInvitations, nil
+}
+
+func (m TeamInvitationModel) Accept(ctx context.Context, teamInvitation *TeamInvitation, user uuid.UUID) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamInvitationModel.Accept")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Use the token, it can be accepted only once
+	err = updateTeamInvitationStatus(ctx, tx, teamInvitation, InvitationAccepted)
+	if err != nil {
+		return err
+	}
+
+	// Add the user to the team
+	query := `
+        INSERT INTO team_members (team_member_team, team_member_user, team_member_role)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (team_member_team, team_member_user) DO NOTHING`
+
+	args := []interface{}{teamInvitation.TeamInvitationTeam, user, teamInvitation.TeamInvitationRole}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// The members are indexed with the team, unless the user was already a member
+	if rowsAffected > 0 {
+		err = touchTeam(ctx, tx, teamInvitation.TeamInvitationTeam)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamInvitationModel) Decline(ctx context.Context, teamInvitation *TeamInvitation) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamInvitationModel.Decline")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateTeamInvitationStatus(ctx, tx, teamInvitation, InvitationDeclined)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateTeamInvitationStatus(ctx context.Context, tx *sql.Tx, teamInvitation *TeamInvitation, status string) error {
+	query := `
+        UPDATE team_invitations
+        SET team_invitation_status = $1
+        WHERE id = $2
+        AND team_invitation_status = 'pending'
+        AND team_invitation_expiry > NOW()`
+
+	result, err := tx.ExecContext(ctx, query, status, teamInvitation.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	teamInvitation.TeamInvitationStatus = status
+
+	return nil
+}
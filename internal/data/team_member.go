@@ -4,18 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/e-inwork-com/go-team-service/internal/validator"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
 )
 
 type TeamMemberModelInterface interface {
-	Insert(teamMember *TeamMember) error
-	GetByID(id uuid.UUID) (*TeamMember, error)
-	ListByOwner(teamMemberTeam uuid.UUID) ([]*TeamMember, error)
-	Delete(teamMember *TeamMember) error
+	Insert(ctx context.Context, teamMember *TeamMember) error
+	GetByID(ctx context.Context, id uuid.UUID) (*TeamMember, error)
+	GetByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) (*TeamMember, error)
+	ListByOwner(ctx context.Context, teamMemberTeam uuid.UUID) ([]*TeamMember, error)
+	ListByTeam(ctx context.Context, teamMemberTeam uuid.UUID, name string, roles []string, filters Filters) ([]*TeamMember, Metadata, error)
+	ListByUser(ctx context.Context, teamMemberUser uuid.UUID) ([]*TeamMember, error)
+	UpdateRole(ctx context.Context, teamMember *TeamMember) error
+	Delete(ctx context.Context, teamMember *TeamMember) error
+	DeleteByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) error
 }
 
 type TeamMember struct {
@@ -26,37 +40,52 @@ type TeamMember struct {
 	TeamMemberUser          uuid.UUID `json:"team_member_user"`
 	TeamMemberUserFirstName string    `json:"team_member_user_first_name"`
 	TeamMemberUserLastName  string    `json:"team_member_user_last_name"`
+	TeamMemberRole          string    `json:"team_member_role"`
 }
 
 func ValidateTeamMember(v *validator.Validator, teamMember *TeamMember) {
 	v.Check(teamMember.TeamMemberTeam != uuid.Nil, "team_member_team", "must be provided")
 	v.Check(teamMember.TeamMemberUser != uuid.Nil, "team_member_user", "must be provided")
+	v.Check(validator.In(teamMember.TeamMemberRole, RoleAdmin, RoleMember, RoleViewer), "team_member_role", "must be admin, member or viewer")
 }
 
 type TeamMemberModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m TeamMemberModel) Insert(teamMember *TeamMember) error {
+func (m TeamMemberModel) Insert(ctx context.Context, teamMember *TeamMember) error {
 	query := `
-        INSERT INTO team_members (team_member_team, team_member_user)
-        VALUES ($1, $2)
+        INSERT INTO team_members (team_member_team, team_member_user, team_member_role)
+        VALUES ($1, $2, $3)
         RETURNING id, created_at`
 
-	args := []interface{}{teamMember.TeamMemberTeam, teamMember.TeamMemberUser}
+	args := []interface{}{teamMember.TeamMemberTeam, teamMember.TeamMemberUser, teamMember.TeamMemberRole}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.Insert")
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&teamMember.ID, &teamMember.CreatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&teamMember.ID, &teamMember.CreatedAt)
+	if err != nil {
+		return constraintError(err)
+	}
+
+	// The members are indexed with the team
+	err = touchTeam(ctx, tx, teamMember.TeamMemberTeam)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (m TeamMemberModel) GetByID(id uuid.UUID) (*TeamMember, error) {
+func (m TeamMemberModel) GetByID(ctx context.Context, id uuid.UUID) (*TeamMember, error) {
 	query := `
     SELECT
 			team_members.id,
@@ -65,7 +94,8 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*TeamMember, error) {
 			teams.team_name as team_member_team_name,
 			team_member_user,
 			users.first_name as team_member_user_first_name,
-			users.last_name as team_member_user_last_name
+			users.last_name as team_member_user_last_name,
+			team_member_role
     FROM team_members, teams, users
 		WHERE team_members.id = $1
 		AND team_member_team = teams.id
@@ -74,7 +104,7 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*TeamMember, error) {
 
 	var teamMember TeamMember
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.GetByID")
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -85,6 +115,7 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*TeamMember, error) {
 		&teamMember.TeamMemberUser,
 		&teamMember.TeamMemberUserFirstName,
 		&teamMember.TeamMemberUserLastName,
+		&teamMember.TeamMemberRole,
 	)
 
 	if err != nil {
@@ -99,7 +130,7 @@ func (m TeamMemberModel) GetByID(id uuid.UUID) (*TeamMember, error) {
 	return &teamMember, nil
 }
 
-func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*TeamMember, error) {
+func (m TeamMemberModel) GetByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) (*TeamMember, error) {
 	query := `
     SELECT
 			team_members.id,
@@ -108,14 +139,63 @@ func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*TeamMember, e
 			teams.team_name as team_member_team_name,
 			team_member_user,
 			users.first_name as team_member_user_first_name,
-			users.last_name as team_member_user_last_name
+			users.last_name as team_member_user_last_name,
+			team_member_role
     FROM team_members, teams, users
 		WHERE team_member_team = $1
+		AND team_member_user = $2
 		AND team_member_team = teams.id
 		AND team_member_user = users.id
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	var teamMember TeamMember
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.GetByTeamAndUser")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, teamMemberTeam, teamMemberUser).Scan(
+		&teamMember.ID,
+		&teamMember.CreatedAt,
+		&teamMember.TeamMemberTeam,
+		&teamMember.TeamMemberTeamName,
+		&teamMember.TeamMemberUser,
+		&teamMember.TeamMemberUserFirstName,
+		&teamMember.TeamMemberUserLastName,
+		&teamMember.TeamMemberRole,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &teamMember, nil
+}
+
+func (m TeamMemberModel) ListByOwner(ctx context.Context, teamMemberTeam uuid.UUID) ([]*TeamMember, error) {
+	query := `
+    SELECT
+			team_members.id,
+			team_members.created_at,
+			team_member_team,
+			teams.team_name as team_member_team_name,
+			team_member_user,
+			users.first_name as team_member_user_first_name,
+			users.last_name as team_member_user_last_name,
+			team_member_role
+    FROM team_members, teams, users
+		WHERE team_member_team = $1
+		AND team_member_team = teams.id
+		AND teams.is_deleted = false
+		AND team_member_user = users.id
+		ORDER BY team_members.created_at, team_members.id
+	`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.ListByOwner")
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, teamMemberTeam)
@@ -137,6 +217,7 @@ func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*TeamMember, e
 			&teamMember.TeamMemberUser,
 			&teamMember.TeamMemberUserFirstName,
 			&teamMember.TeamMemberUserLastName,
+			&teamMember.TeamMemberRole,
 		)
 		if err != nil {
 			return nil, err
@@ -145,22 +226,243 @@ func (m TeamMemberModel) ListByOwner(teamMemberTeam uuid.UUID) ([]*TeamMember, e
 		teamMembers = append(teamMembers, &teamMember)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return teamMembers, nil
 }
 
-func (m TeamMemberModel) Delete(teamMember *TeamMember) error {
+func (m TeamMemberModel) ListByTeam(ctx context.Context, teamMemberTeam uuid.UUID, name string, roles []string, filters Filters) ([]*TeamMember, Metadata, error) {
+	// The total is counted before the cursor, so it stays the same on every page.
+	// The id breaks the ties of the sort column, so a cursor points to a single row.
+	query := fmt.Sprintf(`
+    WITH filtered AS (
+        SELECT
+			team_members.id,
+			team_members.created_at,
+			team_member_team,
+			teams.team_name as team_member_team_name,
+			team_member_user,
+			users.first_name as team_member_user_first_name,
+			users.last_name as team_member_user_last_name,
+			team_member_role,
+			users.first_name || ' ' || users.last_name as name
+        FROM team_members, teams, users
+		WHERE team_member_team = $1
+		AND team_member_team = teams.id
+		AND teams.is_deleted = false
+		AND team_member_user = users.id
+		AND (users.first_name || ' ' || users.last_name ILIKE '%%' || $2 || '%%' OR $2 = '')
+		AND (team_member_role = ANY($3) OR cardinality($3::text[]) = 0)
+    )
+    SELECT
+			(SELECT count(*) FROM filtered),
+			id,
+			created_at,
+			team_member_team,
+			team_member_team_name,
+			team_member_user,
+			team_member_user_first_name,
+			team_member_user_last_name,
+			team_member_role
+    FROM filtered
+		WHERE ($4::boolean = false OR (%s, id) %s ($5, $6))
+		ORDER BY %s %s, id %s
+		LIMIT $7 OFFSET $8
+	`, filters.sortColumn(), filters.cursorOperator(), filters.sortColumn(), filters.sortDirection(), filters.sortDirection())
+
+	args := []interface{}{teamMemberTeam, escapeLike(name), pq.Array(roles), false, nil, nil, filters.limit() + 1, filters.offset()}
+
+	if filters.Cursor != "" {
+		c, err := filters.cursor()
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		args[3], args[4], args[5] = true, c.Value, c.ID
+	}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.ListByTeam")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	teamMembers := []*TeamMember{}
+
+	for rows.Next() {
+		var teamMember TeamMember
+
+		err = rows.Scan(
+			&totalRecords,
+			&teamMember.ID,
+			&teamMember.CreatedAt,
+			&teamMember.TeamMemberTeam,
+			&teamMember.TeamMemberTeamName,
+			&teamMember.TeamMemberUser,
+			&teamMember.TeamMemberUserFirstName,
+			&teamMember.TeamMemberUserLastName,
+			&teamMember.TeamMemberRole,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		teamMembers = append(teamMembers, &teamMember)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// One row more than the page size was read to know if there is a next page
+	nextCursor := ""
+	if len(teamMembers) > filters.limit() {
+		teamMembers = teamMembers[:filters.limit()]
+		nextCursor = teamMemberCursor(teamMembers[len(teamMembers)-1], filters)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters, nextCursor)
+
+	return teamMembers, metadata, nil
+}
+
+// teamMemberCursor returns the cursor of the rows after the team member
+func teamMemberCursor(teamMember *TeamMember, filters Filters) string {
+	c := cursor{Sort: filters.Sort, ID: teamMember.ID}
+
+	switch filters.sortColumn() {
+	case "name":
+		c.Value = teamMember.TeamMemberUserFirstName + " " + teamMember.TeamMemberUserLastName
+	default:
+		c.Value = teamMember.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return encodeCursor(c)
+}
+
+func (m TeamMemberModel) ListByUser(ctx context.Context, teamMemberUser uuid.UUID) ([]*TeamMember, error) {
+	query := `
+    SELECT
+			team_members.id,
+			team_members.created_at,
+			team_member_team,
+			teams.team_name as team_member_team_name,
+			team_member_user,
+			users.first_name as team_member_user_first_name,
+			users.last_name as team_member_user_last_name,
+			team_member_role
+    FROM team_members, teams, users
+		WHERE team_member_user = $1
+		AND team_member_team = teams.id
+		AND teams.is_deleted = false
+		AND team_member_user = users.id
+		ORDER BY team_members.created_at
+	`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.ListByUser")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, teamMemberUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teamMembers := []*TeamMember{}
+
+	for rows.Next() {
+		var teamMember TeamMember
+
+		err = rows.Scan(
+			&teamMember.ID,
+			&teamMember.CreatedAt,
+			&teamMember.TeamMemberTeam,
+			&teamMember.TeamMemberTeamName,
+			&teamMember.TeamMemberUser,
+			&teamMember.TeamMemberUserFirstName,
+			&teamMember.TeamMemberUserLastName,
+			&teamMember.TeamMemberRole,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		teamMembers = append(teamMembers, &teamMember)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teamMembers, nil
+}
+
+func (m TeamMemberModel) UpdateRole(ctx context.Context, teamMember *TeamMember) error {
+	query := `
+        UPDATE team_members
+        SET team_member_role = $1
+        WHERE id = $2
+        RETURNING team_member_team`
+
+	args := []interface{}{
+		teamMember.TeamMemberRole,
+		teamMember.ID,
+	}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.UpdateRole")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&teamMember.TeamMemberTeam)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return constraintError(err)
+		}
+	}
+
+	// The members are indexed with the team
+	err = touchTeam(ctx, tx, teamMember.TeamMemberTeam)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamMemberModel) Delete(ctx context.Context, teamMember *TeamMember) error {
 	query := `
         DELETE FROM team_members
-        WHERE id = $1`
+        WHERE id = $1
+        RETURNING team_member_team`
 
 	args := []interface{}{
 		teamMember.ID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.Delete")
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&teamMember.TeamMemberTeam)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -170,5 +472,53 @@ func (m TeamMemberModel) Delete(teamMember *TeamMember) error {
 		}
 	}
 
-	return nil
+	// The members are indexed with the team
+	err = touchTeam(ctx, tx, teamMember.TeamMemberTeam)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamMemberModel) DeleteByTeamAndUser(ctx context.Context, teamMemberTeam uuid.UUID, teamMemberUser uuid.UUID) error {
+	query := `
+        DELETE FROM team_members
+        WHERE team_member_team = $1 AND team_member_user = $2`
+
+	args := []interface{}{
+		teamMemberTeam,
+		teamMemberUser,
+	}
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamMemberModel.DeleteByTeamAndUser")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	// The members are indexed with the team
+	err = touchTeam(ctx, tx, teamMemberTeam)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
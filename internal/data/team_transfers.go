@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferCancelled = "cancelled"
+)
+
+type TeamTransferModelInterface interface {
+	Insert(ctx context.Context, teamTransfer *TeamTransfer) error
+	GetPendingByTeam(ctx context.Context, teamTransferTeam uuid.UUID) (*TeamTransfer, error)
+	Accept(ctx context.Context, teamTransfer *TeamTransfer, team *Team) error
+	Cancel(ctx context.Context, teamTransfer *TeamTransfer, actor uuid.UUID) error
+}
+
+type TeamTransfer struct {
+	ID                 uuid.UUID `json:"id"`
+	CreatedAt          time.Time `json:"created_at"`
+	TeamTransferTeam   uuid.UUID `json:"team_transfer_team"`
+	TeamTransferFrom   uuid.UUID `json:"team_transfer_from"`
+	TeamTransferTo     uuid.UUID `json:"team_transfer_to"`
+	TeamTransferStatus string    `json:"team_transfer_status"`
+}
+
+func ValidateTeamTransfer(v *validator.Validator, teamTransfer *TeamTransfer) {
+	v.Check(teamTransfer.TeamTransferTo != uuid.Nil, "team_transfer_to", "must be provided")
+	v.Check(teamTransfer.TeamTransferTo != teamTransfer.TeamTransferFrom, "team_transfer_to", "must not be the current owner")
+}
+
+type TeamTransferModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m TeamTransferModel) Insert(ctx context.Context, teamTransfer *TeamTransfer) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamTransferModel.Insert")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A new nomination replaces the pending one
+	query := `
+        INSERT INTO team_transfers (team_transfer_team, team_transfer_from, team_transfer_to)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (team_transfer_team) WHERE team_transfer_status = 'pending'
+        DO UPDATE SET
+            created_at = NOW(),
+            team_transfer_from = EXCLUDED.team_transfer_from,
+            team_transfer_to = EXCLUDED.team_transfer_to
+        RETURNING id, created_at, team_transfer_status`
+
+	args := []interface{}{teamTransfer.TeamTransferTeam, teamTransfer.TeamTransferFrom, teamTransfer.TeamTransferTo}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&teamTransfer.ID,
+		&teamTransfer.CreatedAt,
+		&teamTransfer.TeamTransferStatus,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = insertTeamAuditLog(ctx, tx, teamTransfer.TeamTransferTeam, teamTransfer.TeamTransferFrom, AuditTransferRequested, map[string]string{
+		"team_transfer_id": teamTransfer.ID.String(),
+		"to":               teamTransfer.TeamTransferTo.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamTransferModel) GetPendingByTeam(ctx context.Context, teamTransferTeam uuid.UUID) (*TeamTransfer, error) {
+	query := `
+        SELECT id, created_at, team_transfer_team, team_transfer_from, team_transfer_to, team_transfer_status
+        FROM team_transfers
+        WHERE team_transfer_team = $1 AND team_transfer_status = 'pending'`
+
+	var teamTransfer TeamTransfer
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamTransferModel.GetPendingByTeam")
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, teamTransferTeam).Scan(
+		&teamTransfer.ID,
+		&teamTransfer.CreatedAt,
+		&teamTransfer.TeamTransferTeam,
+		&teamTransfer.TeamTransferFrom,
+		&teamTransfer.TeamTransferTo,
+		&teamTransfer.TeamTransferStatus,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &teamTransfer, nil
+}
+
+func (m TeamTransferModel) Accept(ctx context.Context, teamTransfer *TeamTransfer, team *Team) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamTransferModel.Accept")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Close the nomination
+	err = resolveTeamTransfer(ctx, tx, teamTransfer, TransferAccepted)
+	if err != nil {
+		return err
+	}
+
+	// Swap the owner, unless the team changed owner in the meantime
+	query := `
+        UPDATE teams
+        SET team_user = $1, version = version + 1, index_version = index_version + 1, is_indexed = false, updated_at = NOW()
+        WHERE id = $2 AND team_user = $3 AND is_deleted = false
+        RETURNING team_user, version, index_version`
+
+	args := []interface{}{teamTransfer.TeamTransferTo, team.ID, teamTransfer.TeamTransferFrom}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&team.TeamUser, &team.Version, &team.IndexVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	// The new owner is not a member anymore
+	query = `
+        DELETE FROM team_members
+        WHERE team_member_team = $1 AND team_member_user = $2`
+
+	result, err := tx.ExecContext(ctx, query, team.ID, teamTransfer.TeamTransferTo)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// The nominated user left the team before accepting
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	// The old owner becomes a member
+	query = `
+        INSERT INTO team_members (team_member_team, team_member_user, team_member_role)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (team_member_team, team_member_user) DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, team.ID, teamTransfer.TeamTransferFrom, RoleMember)
+	if err != nil {
+		return err
+	}
+
+	err = insertTeamAuditLog(ctx, tx, team.ID, teamTransfer.TeamTransferTo, AuditTransferAccepted, map[string]string{
+		"team_transfer_id": teamTransfer.ID.String(),
+		"from":             teamTransfer.TeamTransferFrom.String(),
+		"to":               teamTransfer.TeamTransferTo.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Record the indexing event of the new owner
+	err = insertTeamEvent(ctx, tx, team, TeamEventUpsert)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamTransferModel) Cancel(ctx context.Context, teamTransfer *TeamTransfer, actor uuid.UUID) error {
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamTransferModel.Cancel")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = resolveTeamTransfer(ctx, tx, teamTransfer, TransferCancelled)
+	if err != nil {
+		return err
+	}
+
+	err = insertTeamAuditLog(ctx, tx, teamTransfer.TeamTransferTeam, actor, AuditTransferCancelled, map[string]string{
+		"team_transfer_id": teamTransfer.ID.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func resolveTeamTransfer(ctx context.Context, tx *sql.Tx, teamTransfer *TeamTransfer, status string) error {
+	query := `
+        UPDATE team_transfers
+        SET team_transfer_status = $1, resolved_at = NOW()
+        WHERE id = $2 AND team_transfer_status = 'pending'`
+
+	result, err := tx.ExecContext(ctx, query, status, teamTransfer.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	teamTransfer.TeamTransferStatus = status
+
+	return nil
+}
@@ -3,23 +3,31 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"time"
+	"unicode/utf8"
 
-	"github.com/e-inwork-com/go-team-service/internal/grpc/teams"
+	"github.com/e-inwork-com/go-team-service/internal/picture"
 	"github.com/e-inwork-com/go-team-service/internal/validator"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/google/uuid"
 )
 
 type TeamModelInterface interface {
-	Insert(gRPCTeam string, team *Team) error
-	GetByID(id uuid.UUID) (*Team, error)
-	GetByTeamUser(teamUser uuid.UUID) (*Team, error)
-	Update(gRPCTeam string, team *Team) error
+	Insert(ctx context.Context, team *Team) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Team, error)
+	GetByTeamUser(ctx context.Context, teamUser uuid.UUID) (*Team, error)
+	ListByUser(ctx context.Context, user uuid.UUID) ([]*Team, error)
+	Update(ctx context.Context, team *Team) error
+	ListUnindexed(ctx context.Context, limit int) ([]*Team, error)
+	MarkIndexed(ctx context.Context, team *Team) error
+	Delete(ctx context.Context, team *Team) error
+	Restore(ctx context.Context, id uuid.UUID) (*Team, error)
+	ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]*Team, error)
+	Purge(ctx context.Context, team *Team) error
+	PictureReferenced(ctx context.Context, teamPicture string, includeDeleted bool) (bool, error)
 }
 
 type Team struct {
@@ -29,73 +37,80 @@ type Team struct {
 	TeamName    string    `json:"team_name"`
 	TeamPicture string    `json:"team_picture"`
 	Version     int       `json:"-"`
-}
+	IsDeleted   bool      `json:"-"`
 
-type TeamModel struct {
-	DB *sql.DB
+	// IndexVersion also changes with the members of the team,
+	// it orders the documents sent to the indexing service
+	IndexVersion int `json:"-"`
 }
 
-func ValidateTeam(v *validator.Validator, team *Team) {
-	v.Check(team.TeamName != "", "team_name", "must be provided")
-}
+// MarshalJSON adds the URLs of the thumbnails of the team picture
+func (t Team) MarshalJSON() ([]byte, error) {
+	type team Team
 
-func gRPCTeamIndexing(gRPCTeam string, team *Team) error {
-	// Send to gRPC - Go Team Indexing Service
-	con, err := grpc.Dial(gRPCTeam, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
-	}
-	defer con.Close()
+	return json.Marshal(struct {
+		team
+		TeamPictureVariants map[string]string `json:"team_picture_variants,omitempty"`
+	}{
+		team:                team(t),
+		TeamPictureVariants: picture.Variants(t.TeamPicture),
+	})
+}
 
-	c := teams.NewTeamServiceClient(con)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+type TeamModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
 
-	_, err = c.WriteTeam(ctx, &teams.TeamRequest{
-		TeamEntry: &teams.Team{
-			Id: team.ID.String(),
-		},
-	})
-	if err != nil {
-		return err
-	}
+// TeamNameMaxLength is the length of the team_name column, in characters
+const TeamNameMaxLength = 100
 
-	return nil
+func ValidateTeam(v *validator.Validator, team *Team) {
+	v.Check(team.TeamName != "", "team_name", "must be provided")
+	v.Check(utf8.RuneCountInString(team.TeamName) <= TeamNameMaxLength, "team_name", fmt.Sprintf("must not be more than %d characters long", TeamNameMaxLength))
+	v.Check(validator.Printable(team.TeamName), "team_name", "must not contain control characters")
 }
 
-func (m TeamModel) Insert(gRPCTeam string, team *Team) error {
+func (m TeamModel) Insert(ctx context.Context, team *Team) error {
 	query := `
         INSERT INTO teams (team_user, team_name, team_picture)
         VALUES ($1, $2, $3)
-        RETURNING id, created_at, version`
+        RETURNING id, created_at, version, index_version`
 
 	args := []interface{}{team.TeamUser, team.TeamName, team.TeamPicture}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.Insert")
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&team.ID, &team.CreatedAt, &team.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&team.ID, &team.CreatedAt, &team.Version, &team.IndexVersion)
+	if err != nil {
+		return constraintError(err)
+	}
 
-	err = gRPCTeamIndexing(gRPCTeam, team)
+	// Record the indexing event in the same transaction
+	err = insertTeamEvent(ctx, tx, team, TeamEventUpsert)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (m TeamModel) GetByID(id uuid.UUID) (*Team, error) {
+func (m TeamModel) GetByID(ctx context.Context, id uuid.UUID) (*Team, error) {
 	query := `
-        SELECT id, created_at, team_user, team_name, team_picture, version
+        SELECT id, created_at, team_user, team_name, team_picture, version, index_version
         FROM teams
-        WHERE id = $1`
+        WHERE id = $1 AND is_deleted = false`
 
 	var team Team
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.GetByID")
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -105,6 +120,7 @@ func (m TeamModel) GetByID(id uuid.UUID) (*Team, error) {
 		&team.TeamName,
 		&team.TeamPicture,
 		&team.Version,
+		&team.IndexVersion,
 	)
 
 	if err != nil {
@@ -119,19 +135,21 @@ func (m TeamModel) GetByID(id uuid.UUID) (*Team, error) {
 	return &team, nil
 }
 
-func (m TeamModel) GetByTeamUser(teamUser uuid.UUID) (*Team, error) {
+func (m TeamModel) GetByTeamUser(ctx context.Context, teamUser uuid.UUID) (*Team, error) {
 	// Select query by owner
 	query := `
-        SELECT id, created_at, team_user, team_name, team_picture, version
+        SELECT id, created_at, team_user, team_name, team_picture, version, index_version
         FROM teams
-        WHERE team_user = $1`
+        WHERE team_user = $1 AND is_deleted = false
+        ORDER BY created_at, id
+        LIMIT 1`
 
 	// Define a record variable
 	var team Team
 
 	// Create a context background
 	// to use it with a query to database
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.GetByTeamUser")
 	defer cancel()
 
 	// Query by owner to the database,
@@ -143,6 +161,7 @@ func (m TeamModel) GetByTeamUser(teamUser uuid.UUID) (*Team, error) {
 		&team.TeamName,
 		&team.TeamPicture,
 		&team.Version,
+		&team.IndexVersion,
 	)
 
 	// Check error
@@ -159,13 +178,63 @@ func (m TeamModel) GetByTeamUser(teamUser uuid.UUID) (*Team, error) {
 	return &team, nil
 }
 
-func (m TeamModel) Update(gRPCTeam string, team *Team) error {
+func (m TeamModel) ListByUser(ctx context.Context, user uuid.UUID) ([]*Team, error) {
+	// Select the teams owned by the user,
+	// and the teams where the user is a member
+	query := `
+        SELECT teams.id, teams.created_at, team_user, team_name, team_picture, version, index_version
+        FROM teams
+        LEFT JOIN team_members
+        ON team_members.team_member_team = teams.id
+        AND team_members.team_member_user = $1
+        WHERE teams.is_deleted = false
+        AND (teams.team_user = $1 OR team_members.id IS NOT NULL)
+        ORDER BY teams.created_at, teams.id`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.ListByUser")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*Team{}
+
+	for rows.Next() {
+		var team Team
+
+		err = rows.Scan(
+			&team.ID,
+			&team.CreatedAt,
+			&team.TeamUser,
+			&team.TeamName,
+			&team.TeamPicture,
+			&team.Version,
+			&team.IndexVersion,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, &team)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (m TeamModel) Update(ctx context.Context, team *Team) error {
 	// SQL Update
 	query := `
         UPDATE teams
-        SET team_name = $1, team_picture = $2, version = version + 1, is_indexed = false
-        WHERE id = $3 AND version = $4
-        RETURNING version`
+        SET team_name = $1, team_picture = $2, version = version + 1, index_version = index_version + 1, is_indexed = false, updated_at = NOW()
+        WHERE id = $3 AND version = $4 AND is_deleted = false
+        RETURNING version, index_version`
 
 	// Assign arguments
 	args := []interface{}{
@@ -176,11 +245,253 @@ func (m TeamModel) Update(gRPCTeam string, team *Team) error {
 	}
 
 	// Create a context of the SQL Update
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.Update")
 	defer cancel()
 
-	// Run SQL Update
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&team.Version)
+	// Start a transaction for the update and the indexing event
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Run SQL Update, no row is updated when the team
+	// changed since it was read, or when it was deleted
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&team.Version, &team.IndexVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	// Record the indexing event in the same transaction
+	err = insertTeamEvent(ctx, tx, team, TeamEventUpsert)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamModel) ListUnindexed(ctx context.Context, limit int) ([]*Team, error) {
+	// Select the teams that are not indexed yet, a changed team is not
+	// indexed until the new version is. The oldest first, so a stream
+	// of new changes can't hold the others back.
+	query := `
+        SELECT id, created_at, team_user, team_name, team_picture, version, index_version, is_deleted
+        FROM teams
+        WHERE is_indexed = false
+        ORDER BY updated_at, id
+        LIMIT $1`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.ListUnindexed")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*Team{}
+
+	for rows.Next() {
+		var team Team
+
+		err = rows.Scan(
+			&team.ID,
+			&team.CreatedAt,
+			&team.TeamUser,
+			&team.TeamName,
+			&team.TeamPicture,
+			&team.Version,
+			&team.IndexVersion,
+			&team.IsDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, &team)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (m TeamModel) MarkIndexed(ctx context.Context, team *Team) error {
+	// Only flag the version that has been indexed
+	query := `
+        UPDATE teams
+        SET is_indexed = true
+        WHERE id = $1 AND index_version = $2`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.MarkIndexed")
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, team.ID, team.IndexVersion)
+	return err
+}
+
+func (m TeamModel) Delete(ctx context.Context, team *Team) error {
+	// Soft delete, the record is purged after the retention window
+	query := `
+        UPDATE teams
+        SET is_deleted = true, deleted_at = NOW(), version = version + 1, index_version = index_version + 1, is_indexed = false, updated_at = NOW()
+        WHERE id = $1 AND version = $2 AND is_deleted = false
+        RETURNING version, index_version`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.Delete")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// No row is deleted when the team changed since it was read, or when it was deleted
+	err = tx.QueryRowContext(ctx, query, team.ID, team.Version).Scan(&team.Version, &team.IndexVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	team.IsDeleted = true
+
+	// Record the indexing event in the same transaction
+	err = insertTeamEvent(ctx, tx, team, TeamEventDelete)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TeamModel) Restore(ctx context.Context, id uuid.UUID) (*Team, error) {
+	query := `
+        UPDATE teams
+        SET is_deleted = false, deleted_at = NULL, version = version + 1, index_version = index_version + 1, is_indexed = false, updated_at = NOW()
+        WHERE id = $1 AND is_deleted = true
+        RETURNING id, created_at, team_user, team_name, team_picture, version, index_version`
+
+	var team Team
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.Restore")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&team.ID,
+		&team.CreatedAt,
+		&team.TeamUser,
+		&team.TeamName,
+		&team.TeamPicture,
+		&team.Version,
+		&team.IndexVersion,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// Record the indexing event in the same transaction
+	err = insertTeamEvent(ctx, tx, &team, TeamEventUpsert)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (m TeamModel) ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]*Team, error) {
+	// Select the teams deleted before the given time
+	query := `
+        SELECT id, created_at, team_user, team_name, team_picture, version, index_version, is_deleted
+        FROM teams
+        WHERE is_deleted = true AND deleted_at < $1
+        ORDER BY deleted_at
+        LIMIT $2`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.ListPurgeable")
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*Team{}
+
+	for rows.Next() {
+		var team Team
+
+		err = rows.Scan(
+			&team.ID,
+			&team.CreatedAt,
+			&team.TeamUser,
+			&team.TeamName,
+			&team.TeamPicture,
+			&team.Version,
+			&team.IndexVersion,
+			&team.IsDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, &team)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (m TeamModel) Purge(ctx context.Context, team *Team) error {
+	// Hard delete, the members are removed by the cascade
+	query := `
+        DELETE FROM teams
+        WHERE id = $1 AND is_deleted = true
+        RETURNING index_version + 1`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.Purge")
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, team.ID).Scan(&team.IndexVersion)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -190,10 +501,33 @@ func (m TeamModel) Update(gRPCTeam string, team *Team) error {
 		}
 	}
 
-	err = gRPCTeamIndexing(gRPCTeam, team)
+	// Delete the team from the index again, in case the event
+	// of the soft delete ran out of delivery attempts
+	err = insertTeamEvent(ctx, tx, team, TeamEventDelete)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PictureReferenced reports whether a team refers to the picture, the pictures
+// are named by their content so several teams can share the same picture
+func (m TeamModel) PictureReferenced(ctx context.Context, teamPicture string, includeDeleted bool) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1 FROM teams
+            WHERE team_picture = $1 AND (is_deleted = false OR $2))`
+
+	ctx, cancel := queryContext(ctx, m.Timeout, "TeamModel.PictureReferenced")
+	defer cancel()
+
+	var referenced bool
+
+	err := m.DB.QueryRowContext(ctx, query, teamPicture, includeDeleted).Scan(&referenced)
 	if err != nil {
-		log.Println(err)
+		return false, err
 	}
 
-	return nil
+	return referenced, nil
 }
@@ -12,7 +12,7 @@ import (
 var AnonymousUser = &User{}
 
 type UserModelInterface interface {
-	GetByID(id uuid.UUID) (*User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
 }
 
 type User struct {
@@ -26,14 +26,15 @@ type User struct {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
-func (m UserModel) GetByID(id uuid.UUID) (*User, error) {
+func (m UserModel) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	query := `
         SELECT id, created_at, email, first_name, last_name, activated, version
         FROM users
@@ -41,7 +42,7 @@ func (m UserModel) GetByID(id uuid.UUID) (*User, error) {
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := queryContext(ctx, m.Timeout, "UserModel.GetByID")
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
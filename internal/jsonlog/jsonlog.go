@@ -2,17 +2,22 @@ package jsonlog
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Level int8
 
 const (
-	LevelInfo Level = iota
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
 	LevelError
 	LevelFatal
 	LevelOff
@@ -20,54 +25,204 @@ const (
 
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+// ParseLevel returns the level of its name, in any case
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// MarshalText and UnmarshalText let a level be read by flag.TextVar and JSON
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = level
+	return nil
+}
+
+// Field is a typed property of a log entry
+type Field struct {
+	Key   string
+	Value any
+}
+
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration is written in milliseconds
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: float64(value.Microseconds()) / 1000}
+}
+
+// Stringer is written with the String method of the value, like a UUID
+func Stringer(key string, value fmt.Stringer) Field {
+	return Field{Key: key, Value: value.String()}
+}
+
+func Any(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// output is shared by a logger and its children, so a
+// change of the level applies to all of them at once
+type output struct {
+	out         io.Writer
+	mu          sync.Mutex
+	minLevel    atomic.Int32
+	stackTraces atomic.Bool
+}
+
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	mu       sync.Mutex
+	output *output
+	fields []Field
 }
 
 func New(out io.Writer, minLevel Level) *Logger {
+	l := &Logger{output: &output{out: out}}
+	l.SetLevel(minLevel)
+
+	return l
+}
+
+// SetLevel changes the minimum level of the logger and of its children
+func (l *Logger) SetLevel(level Level) {
+	l.output.minLevel.Store(int32(level))
+}
+
+func (l *Logger) Level() Level {
+	return Level(l.output.minLevel.Load())
+}
+
+// Enabled reports whether an entry of the level is written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// SetStackTraces adds the stack trace to the ERROR and FATAL entries
+func (l *Logger) SetStackTraces(enabled bool) {
+	l.output.stackTraces.Store(enabled)
+}
+
+// With returns a child logger which adds the fields to every entry,
+// a field of an entry replaces the bound field with the same key
+func (l *Logger) With(fields ...Field) *Logger {
 	return &Logger{
-		out:      out,
-		minLevel: minLevel,
+		output: l.output,
+		fields: append(l.fields[:len(l.fields):len(l.fields)], fields...),
 	}
 }
 
+func (l *Logger) Debug(message string, fields ...Field) {
+	l.print(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Field) {
+	l.print(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...Field) {
+	l.print(LevelWarn, message, fields)
+}
+
+func (l *Logger) Error(err error, fields ...Field) {
+	l.print(LevelError, err.Error(), fields)
+}
+
+func (l *Logger) Fatal(err error, fields ...Field) {
+	l.print(LevelFatal, err.Error(), fields)
+	os.Exit(1)
+}
+
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
-	l.print(LevelInfo, message, properties)
+	l.print(LevelInfo, message, stringFields(properties))
 }
 
 func (l *Logger) PrintError(err error, properties map[string]string) {
-	l.print(LevelError, err.Error(), properties)
+	l.print(LevelError, err.Error(), stringFields(properties))
 }
 
 func (l *Logger) PrintFatal(err error, properties map[string]string) {
-	l.print(LevelFatal, err.Error(), properties)
+	l.print(LevelFatal, err.Error(), stringFields(properties))
 	os.Exit(1)
 }
 
-func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
-	if level < l.minLevel {
+func stringFields(properties map[string]string) []Field {
+	fields := make([]Field, 0, len(properties))
+	for key, value := range properties {
+		fields = append(fields, String(key, value))
+	}
+
+	return fields
+}
+
+func (l *Logger) print(level Level, message string, fields []Field) (int, error) {
+	if !l.Enabled(level) {
 		return 0, nil
 	}
 
+	var properties map[string]any
+	if len(l.fields)+len(fields) > 0 {
+		properties = make(map[string]any, len(l.fields)+len(fields))
+
+		for _, field := range l.fields {
+			properties[field.Key] = field.Value
+		}
+		for _, field := range fields {
+			properties[field.Key] = field.Value
+		}
+	}
+
 	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
+		Level      string         `json:"level"`
+		Time       string         `json:"time"`
+		Message    string         `json:"message"`
+		Properties map[string]any `json:"properties,omitempty"`
+		Trace      string         `json:"trace,omitempty"`
 	}{
 		Level:      level.String(),
 		Time:       time.Now().UTC().Format(time.RFC3339),
@@ -75,7 +230,7 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 		Properties: properties,
 	}
 
-	if level >= LevelError {
+	if level >= LevelError && l.output.stackTraces.Load() {
 		aux.Trace = string(debug.Stack())
 	}
 
@@ -86,10 +241,10 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 		line = []byte(LevelError.String() + ": unable to marshal log message:" + err.Error())
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.output.mu.Lock()
+	defer l.output.mu.Unlock()
 
-	return l.out.Write(append(line, '\n'))
+	return l.output.out.Write(append(line, '\n'))
 }
 
 func (l *Logger) Write(message []byte) (n int, err error) {
@@ -0,0 +1,135 @@
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEntries decodes the lines written by a logger
+func testEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var entries []map[string]any
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var entry map[string]any
+		err := json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	assert.Nil(t, err)
+	assert.Equal(t, LevelDebug, level)
+
+	level, err = ParseLevel("WARN")
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.NotNil(t, err)
+
+	err = level.UnmarshalText([]byte("off"))
+	assert.Nil(t, err)
+	assert.Equal(t, LevelOff, level)
+}
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+	logger.Warn("shown")
+
+	entries := testEntries(t, &buf)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "INFO", entries[0]["level"])
+	assert.Equal(t, "WARN", entries[1]["level"])
+
+	// A change of the level applies to the children
+	child := logger.With(String("request_id", "1"))
+	logger.SetLevel(LevelDebug)
+	assert.True(t, child.Enabled(LevelDebug))
+
+	logger.SetLevel(LevelOff)
+	buf.Reset()
+	child.Error(errors.New("hidden"))
+	assert.Empty(t, buf.String())
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	child := logger.With(String("request_id", "1"), String("user_id", "2"))
+	child.With(String("team_id", "3")).Info("request",
+		Int("status", 200),
+		Duration("duration_ms", 1500*time.Microsecond),
+		String("user_id", "4"),
+	)
+	logger.Info("plain")
+
+	entries := testEntries(t, &buf)
+	assert.Len(t, entries, 2)
+
+	properties := entries[0]["properties"].(map[string]any)
+	assert.Equal(t, "1", properties["request_id"])
+	assert.Equal(t, "3", properties["team_id"])
+	assert.Equal(t, "4", properties["user_id"])
+	assert.Equal(t, float64(200), properties["status"])
+	assert.Equal(t, 1.5, properties["duration_ms"])
+
+	// The parent logger isn't changed by its children
+	assert.Nil(t, entries[1]["properties"])
+}
+
+func TestStackTraces(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	logger.Error(errors.New("failure"))
+	assert.Nil(t, testEntries(t, &buf)[0]["trace"])
+
+	buf.Reset()
+	logger.SetStackTraces(true)
+	logger.Error(errors.New("failure"))
+	assert.NotEmpty(t, testEntries(t, &buf)[0]["trace"])
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(New(&buf, LevelInfo)))
+
+	logger.Debug("hidden")
+	logger.With("request_id", "1").WithGroup("db").Warn("slow query",
+		"duration", 2*time.Millisecond,
+		"err", errors.New("timeout"),
+	)
+
+	entries := testEntries(t, &buf)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "WARN", entries[0]["level"])
+	assert.Equal(t, "slow query", entries[0]["message"])
+
+	properties := entries[0]["properties"].(map[string]any)
+	assert.Equal(t, "1", properties["request_id"])
+	assert.Equal(t, float64(2), properties["db.duration"])
+	assert.Equal(t, "timeout", properties["db.err"])
+}
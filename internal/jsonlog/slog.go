@@ -0,0 +1,98 @@
+package jsonlog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler is a slog.Handler which writes the records through a Logger,
+// the attributes of a group are written with the keys prefixed by the group
+type Handler struct {
+	logger *Logger
+	prefix string
+}
+
+func NewHandler(l *Logger) *Handler {
+	return &Handler{logger: l}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(fromSlogLevel(level))
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	fields := make([]Field, 0, r.NumAttrs())
+
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	_, err := h.logger.print(fromSlogLevel(r.Level), r.Message, fields)
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+
+	return &Handler{logger: h.logger.With(fields...), prefix: h.prefix}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &Handler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+// fromSlogLevel returns the level of a slog level, the levels between
+// two slog levels belong to the lower one
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+
+	// An empty attribute is ignored, as the slog handlers do
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		// The attributes of a group without a key are inlined
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, groupPrefix, ga)
+		}
+
+		return fields
+	case slog.KindDuration:
+		return append(fields, Duration(prefix+a.Key, a.Value.Duration()))
+	}
+
+	value := a.Value.Any()
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	return append(fields, Field{Key: prefix + a.Key, Value: value})
+}
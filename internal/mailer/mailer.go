@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+)
+
+var ErrInvalidHeader = errors.New("invalid email header")
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(recipient string, subject string, body string) error
+}
+
+type SMTP struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+func NewSMTP(host string, port int, username string, password string, sender string) *SMTP {
+	m := &SMTP{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		sender: sender,
+	}
+
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+func (m *SMTP) Send(recipient string, subject string, body string) error {
+	msg, err := message(m.sender, recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.sender, []string{recipient}, msg)
+}
+
+// message returns the email to send, a header value can't add
+// another header: the addresses are rejected when they hold a line
+// break, and the line breaks of the subject are removed before it is encoded
+func message(sender string, recipient string, subject string, body string) ([]byte, error) {
+	if strings.ContainsAny(sender, "\r\n") || strings.ContainsAny(recipient, "\r\n") {
+		return nil, ErrInvalidHeader
+	}
+
+	subject = strings.NewReplacer("\r", "", "\n", "").Replace(subject)
+
+	var msg strings.Builder
+
+	fmt.Fprintf(&msg, "From: %s\r\n", sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	return []byte(msg.String()), nil
+}
+
+type Message struct {
+	Recipient string
+	Subject   string
+	Body      string
+}
+
+// Log writes the emails to the logs instead of sending them and keeps nothing,
+// it is used in development when SMTP is not configured. The body holds
+// the invitation tokens, so it is only written at the DEBUG level.
+type Log struct {
+	logger *jsonlog.Logger
+}
+
+func NewLog(logger *jsonlog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (m *Log) Send(recipient string, subject string, body string) error {
+	m.logger.Warn("email not sent, smtp host is not set",
+		jsonlog.String("recipient", recipient),
+		jsonlog.String("subject", subject),
+	)
+	m.logger.Debug("email body", jsonlog.String("recipient", recipient), jsonlog.String("body", body))
+
+	return nil
+}
+
+// Memory keeps the emails instead of sending them, it is used by the tests
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(recipient string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, Message{
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      body,
+	})
+
+	return nil
+}
+
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+
+	return messages
+}
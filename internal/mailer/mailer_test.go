@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/e-inwork-com/go-team-service/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	msg, err := message("team@doe.com", "nina@doe.com", "You are invited to join Doe\r\nBcc: eve@doe.com", "Hi")
+	assert.Nil(t, err)
+
+	headers, _, _ := strings.Cut(string(msg), "\r\n\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "\r\nSubject: You are invited to join DoeBcc: eve@doe.com\r\n")
+
+	// A subject out of ASCII is encoded
+	msg, err = message("team@doe.com", "nina@doe.com", "You are invited to join Doe's Équipe", "Hi")
+	assert.Nil(t, err)
+	assert.Contains(t, string(msg), "Subject: =?utf-8?q?")
+
+	_, err = message("team@doe.com", "nina@doe.com\r\nBcc: eve@doe.com", "Invitation", "Hi")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLog(jsonlog.New(&buf, jsonlog.LevelInfo))
+
+	err := m.Send("nina@doe.com", "Invitation", "token=secret")
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "nina@doe.com")
+	assert.NotContains(t, buf.String(), "secret")
+}
@@ -0,0 +1,257 @@
+package picture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported picture format")
+	ErrTooLarge    = errors.New("picture dimensions too large")
+)
+
+// Sizes are the thumbnail sizes generated for every picture,
+// a thumbnail fits into a square of the size
+var Sizes = []int{64, 256, 1024}
+
+// BaseURL is the path of the pictures endpoint
+const BaseURL = "/service/teams/pictures/"
+
+// NameRX matches the names given to the pictures: the content hash, or the
+// UUID of the pictures uploaded before, with the extension of the format
+var NameRX = regexp.MustCompile(`^(?:[0-9a-f]{64}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\.(?:jpg|jpeg|png)$`)
+
+// LegacyNameRX matches the names of the pictures uploaded before they were
+// processed: the UUID of the owner with the extension of the uploaded file
+var LegacyNameRX = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}(?:\.[A-Za-z0-9]{1,16})?$`)
+
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// Picture is an uploaded picture re-encoded without its metadata
+type Picture struct {
+	Ext         string
+	ContentType string
+	Original    []byte
+	Variants    []Variant
+
+	// sum is the hash of the re-encoded original,
+	// computed while it's encoded into memory
+	sum []byte
+}
+
+// Process decodes a JPEG or PNG picture, applies the EXIF orientation and
+// re-encodes it with its thumbnails. The encoders don't write any metadata,
+// so the EXIF data (including the GPS location) is stripped. The whole picture
+// is read and decoded in memory, the caller limits the size of the reader.
+func Process(r io.Reader, maxWidth int, maxHeight int) (*Picture, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the dimensions before the picture is decoded in memory
+	config, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, ErrUnsupported
+	}
+
+	if config.Width > maxWidth || config.Height > maxHeight {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	pic := &Picture{Ext: ".png", ContentType: "image/png"}
+
+	if format == "jpeg" {
+		pic.Ext = ".jpg"
+		pic.ContentType = "image/jpeg"
+		img = orient(img, exifOrientation(raw))
+	}
+
+	var original bytes.Buffer
+	hash := sha256.New()
+
+	err = encode(io.MultiWriter(&original, hash), img, format)
+	if err != nil {
+		return nil, err
+	}
+
+	pic.Original = original.Bytes()
+	pic.sum = hash.Sum(nil)
+
+	for _, size := range Sizes {
+		var variant bytes.Buffer
+
+		err := encode(&variant, resize(img, size), format)
+		if err != nil {
+			return nil, err
+		}
+
+		pic.Variants = append(pic.Variants, Variant{Size: size, Data: variant.Bytes()})
+	}
+
+	return pic, nil
+}
+
+// Name returns the name of the picture from the hash of its content,
+// a changed picture always gets a new name so it can be cached forever
+func (p *Picture) Name() string {
+	return hex.EncodeToString(p.sum) + p.Ext
+}
+
+func encode(w io.Writer, img image.Image, format string) error {
+	if format == "jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+
+	return png.Encode(w, img)
+}
+
+// resize scales the picture down to fit into a square of the size,
+// a smaller picture is kept as it is
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width <= size && height <= size {
+		return img
+	}
+
+	if width >= height {
+		width, height = size, height*size/width
+	} else {
+		width, height = width*size/height, size
+	}
+
+	// Keep at least a pixel of a very narrow picture
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	return dst
+}
+
+// ValidName reports whether the name is the name of a stored picture
+func ValidName(name string) bool {
+	return NameRX.MatchString(name)
+}
+
+// LegacyName reports whether the name is the name of a picture uploaded
+// before they were processed, such a picture has no thumbnails
+func LegacyName(name string) bool {
+	return LegacyNameRX.MatchString(name)
+}
+
+// ContentType returns the type of a picture from the extension of its name
+func ContentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return "image/png"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// ValidSize reports whether the size is one of the thumbnail sizes
+func ValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VariantName returns the name of the thumbnail of a picture,
+// the size 0 is the original picture
+func VariantName(name string, size int) string {
+	if size == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), size, ext)
+}
+
+// OriginalName returns the name of the picture of a stored file, which is
+// the picture itself or one of its thumbnails, or false for any other file
+func OriginalName(name string) (string, bool) {
+	if ValidName(name) {
+		return name, true
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	i := strings.LastIndex(base, "_")
+	if i < 0 {
+		return "", false
+	}
+
+	size, err := strconv.Atoi(base[i+1:])
+	if err != nil || !ValidSize(size) {
+		return "", false
+	}
+
+	original := base[:i] + ext
+	if !ValidName(original) {
+		return "", false
+	}
+
+	return original, true
+}
+
+// URL returns the URL of the picture served by the pictures endpoint
+func URL(name string, size int) string {
+	if name == "" {
+		return ""
+	}
+
+	if size == 0 {
+		return BaseURL + name
+	}
+
+	return BaseURL + name + "?size=" + strconv.Itoa(size)
+}
+
+// Variants returns the URLs of the thumbnails of a picture by size
+func Variants(name string) map[string]string {
+	if name == "" {
+		return nil
+	}
+
+	variants := make(map[string]string, len(Sizes))
+	for _, size := range Sizes {
+		variants[strconv.Itoa(size)] = URL(name, size)
+	}
+
+	return variants
+}
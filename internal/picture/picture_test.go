@@ -0,0 +1,123 @@
+package picture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testJPEG returns a JPEG with an EXIF segment holding the orientation
+func testJPEG(t *testing.T, width int, height int, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// TIFF header and a single IFD entry
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = append(tiff, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	raw := buf.Bytes()
+
+	return append(append(append([]byte{}, raw[:2]...), app1...), raw[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	raw := testJPEG(t, 300, 200, 6)
+	assert.Equal(t, 6, exifOrientation(raw))
+
+	pic, err := Process(bytes.NewReader(raw), 4096, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ".jpg", pic.Ext)
+	assert.Regexp(t, "^[0-9a-f]{64}\\.jpg$", pic.Name())
+
+	sum := sha256.Sum256(pic.Original)
+	assert.Equal(t, hex.EncodeToString(sum[:])+".jpg", pic.Name())
+	assert.False(t, bytes.Contains(pic.Original, []byte("Exif")))
+
+	// The orientation 6 is rotated upright
+	config, _, err := image.DecodeConfig(bytes.NewReader(pic.Original))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, config.Width)
+	assert.Equal(t, 300, config.Height)
+
+	sizes := map[int][2]int{64: {42, 64}, 256: {170, 256}, 1024: {200, 300}}
+	for _, variant := range pic.Variants {
+		config, _, err := image.DecodeConfig(bytes.NewReader(variant.Data))
+		assert.Nil(t, err)
+		assert.Equal(t, sizes[variant.Size], [2]int{config.Width, config.Height})
+	}
+
+	_, err = Process(bytes.NewReader(raw), 100, 100)
+	assert.True(t, errors.Is(err, ErrTooLarge))
+
+	_, err = Process(bytes.NewReader([]byte("GIF89a")), 4096, 4096)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
+
+func TestVariantName(t *testing.T) {
+	assert.Equal(t, "team.jpg", VariantName("team.jpg", 0))
+	assert.Equal(t, "team_256.jpg", VariantName("team.jpg", 256))
+
+	name := strings.Repeat("a", 64) + ".png"
+	original, ok := OriginalName(VariantName(name, 64))
+	assert.True(t, ok)
+	assert.Equal(t, name, original)
+
+	_, ok = OriginalName(strings.Repeat("a", 64) + "_100.png")
+	assert.False(t, ok)
+	assert.Equal(t, "/service/teams/pictures/team.jpg?size=64", URL("team.jpg", 64))
+}
+
+func TestName(t *testing.T) {
+	assert.True(t, ValidName(strings.Repeat("a", 64)+".png"))
+	assert.False(t, ValidName("77134e81-0cbe-4148-bb41-f0eecd56ac1d.JPG"))
+
+	// The pictures uploaded before are named by their owner
+	for _, name := range []string{
+		"77134e81-0cbe-4148-bb41-f0eecd56ac1d.JPG",
+		"77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpeg",
+		"77134e81-0cbe-4148-bb41-f0eecd56ac1d",
+	} {
+		assert.True(t, LegacyName(name), name)
+	}
+
+	for _, name := range []string{
+		"team.jpg",
+		"77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg.png",
+		"77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg\x00",
+		"../77134e81-0cbe-4148-bb41-f0eecd56ac1d.jpg",
+	} {
+		assert.False(t, LegacyName(name), name)
+	}
+
+	assert.Equal(t, "image/png", ContentType("77134e81-0cbe-4148-bb41-f0eecd56ac1d.PNG"))
+	assert.Equal(t, "image/jpeg", ContentType("77134e81-0cbe-4148-bb41-f0eecd56ac1d"))
+}
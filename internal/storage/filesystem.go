@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem stores the blobs in a local folder
+type Filesystem struct {
+	dir string
+}
+
+func NewFilesystem(dir string) *Filesystem {
+	return &Filesystem{dir: dir}
+}
+
+func (s *Filesystem) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	// Create the folder if it doesn't already exist
+	err := os.MkdirAll(s.dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a blob
+	// is never read while it is partially written
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+func (s *Filesystem) Get(ctx context.Context, name string) (*Blob, error) {
+	if !validName(name) {
+		return nil, ErrNotFound
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	blob := &Blob{
+		ReadSeekCloser: file,
+		Size:           info.Size(),
+		ContentType:    mime.TypeByExtension(filepath.Ext(name)),
+		ModTime:        info.ModTime(),
+	}
+
+	return blob, nil
+}
+
+func (s *Filesystem) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	err := os.Remove(filepath.Join(s.dir, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Filesystem) List(ctx context.Context) ([]*Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return []*Object{}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	objects := []*Object{}
+
+	for _, entry := range entries {
+		// Skip the folders and the temporary files of the uploads
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Deleted since the folder was read
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		objects = append(objects, &Object{Name: entry.Name(), ModTime: info.ModTime()})
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type memoryBlob struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// memoryReader closes a reader of the memory
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+// Memory keeps the blobs in memory, it is used by the tests
+type Memory struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+func NewMemory() *Memory {
+	return &Memory{blobs: make(map[string]memoryBlob)}
+}
+
+func (s *Memory) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[name] = memoryBlob{data: data, contentType: contentType, modTime: time.Now()}
+
+	return nil
+}
+
+func (s *Memory) Get(ctx context.Context, name string) (*Blob, error) {
+	if !validName(name) {
+		return nil, ErrNotFound
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.blobs[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	blob := &Blob{
+		ReadSeekCloser: memoryReader{bytes.NewReader(b.data)},
+		Size:           int64(len(b.data)),
+		ContentType:    b.contentType,
+		ModTime:        b.modTime,
+	}
+
+	return blob, nil
+}
+
+func (s *Memory) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, name)
+
+	return nil
+}
+
+func (s *Memory) List(ctx context.Context) ([]*Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects := []*Object{}
+	for name, b := range s.blobs {
+		objects = append(objects, &Object{Name: name, ModTime: b.modTime})
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 stores the blobs in a bucket of an S3 compatible
+// object storage like AWS S3 or MinIO
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3(endpoint string, region string, bucket string, accessKey string, secretKey string, useSSL bool) (*S3, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{client: client, bucket: bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, name, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, name string) (*Blob, error) {
+	if !validName(name) {
+		return nil, ErrNotFound
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.err(err)
+	}
+
+	// The object is only requested on the first call
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, s.err(err)
+	}
+
+	blob := &Blob{
+		ReadSeekCloser: object,
+		Size:           info.Size,
+		ContentType:    info.ContentType,
+		ModTime:        info.LastModified,
+	}
+
+	return blob, nil
+}
+
+func (s *S3) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	// Removing a missing object isn't an error
+	return s.err(s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{}))
+}
+
+func (s *S3) List(ctx context.Context) ([]*Object, error) {
+	objects := []*Object{}
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+
+		objects = append(objects, &Object{Name: info.Key, ModTime: info.LastModified})
+	}
+
+	return objects, nil
+}
+
+// err maps the missing objects to ErrNotFound
+func (s *S3) err(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrNotFound
+	default:
+		return err
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("blob not found")
+	ErrInvalidName = errors.New("invalid blob name")
+)
+
+// Storage keeps the uploaded files, so every replica
+// of the service can serve them
+type Storage interface {
+	Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, name string) (*Blob, error)
+	Delete(ctx context.Context, name string) error
+	List(ctx context.Context) ([]*Object, error)
+}
+
+// Object is the name and the modification time of a stored file
+type Object struct {
+	Name    string
+	ModTime time.Time
+}
+
+// Blob is a stored file, the caller has to close it
+type Blob struct {
+	io.ReadSeekCloser
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// validName reports whether the name is a single path element,
+// a blob can't be read or written outside of its storage
+func validName(name string) bool {
+	return name != "" &&
+		name != "." &&
+		name != ".." &&
+		!strings.ContainsAny(name, `/\`) &&
+		path.Base(name) == name
+}
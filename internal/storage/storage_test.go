@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+// testS3Server is a minimal stand-in of an S3 compatible server,
+// it only knows the object requests used by the S3 storage
+type testS3Server struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s *testS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.URL.Path
+
+	// List the objects of the bucket
+	if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<ListBucketResult><Name>teams</Name><IsTruncated>false</IsTruncated>`)
+		for object := range s.objects {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>1</Size></Contents>`,
+				strings.TrimPrefix(object, r.URL.Path), time.Now().UTC().Format(time.RFC3339))
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[key] = body
+		s.types[key] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		body, ok := s.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>%s</Key></Error>`, key)
+			return
+		}
+
+		w.Header().Set("Content-Type", s.types[key])
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func testS3(t *testing.T) *S3 {
+	ts := httptest.NewTLSServer(&testS3Server{
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+	})
+	t.Cleanup(ts.Close)
+
+	client, err := minio.New(strings.TrimPrefix(ts.URL, "https://"), &minio.Options{
+		Creds:     credentials.NewStaticV4("access", "secret", ""),
+		Secure:    true,
+		Region:    "us-east-1",
+		Transport: ts.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &S3{client: client, bucket: "teams"}
+}
+
+func TestStorage(t *testing.T) {
+	stores := map[string]Storage{
+		"Filesystem": NewFilesystem(t.TempDir()),
+		"Memory":     NewMemory(),
+		"S3":         testS3(t),
+	}
+
+	content := []byte("team picture")
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			err := store.Put(ctx, "team.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg")
+			assert.Nil(t, err)
+
+			blob, err := store.Get(ctx, "team.jpg")
+			assert.Nil(t, err)
+			if err == nil {
+				got, _ := io.ReadAll(blob)
+				blob.Close()
+
+				assert.Equal(t, content, got)
+				assert.Equal(t, int64(len(content)), blob.Size)
+				assert.Equal(t, "image/jpeg", blob.ContentType)
+			}
+
+			objects, err := store.List(ctx)
+			assert.Nil(t, err)
+			if assert.Len(t, objects, 1) {
+				assert.Equal(t, "team.jpg", objects[0].Name)
+			}
+
+			_, err = store.Get(ctx, "missing.jpg")
+			assert.True(t, errors.Is(err, ErrNotFound))
+
+			err = store.Delete(ctx, "team.jpg")
+			assert.Nil(t, err)
+
+			_, err = store.Get(ctx, "team.jpg")
+			assert.True(t, errors.Is(err, ErrNotFound))
+
+			err = store.Put(ctx, "../team.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg")
+			assert.True(t, errors.Is(err, ErrInvalidName))
+
+			_, err = store.Get(ctx, "../team.jpg")
+			assert.True(t, errors.Is(err, ErrNotFound))
+
+			err = store.Delete(ctx, "../team.jpg")
+			assert.True(t, errors.Is(err, ErrInvalidName))
+		})
+	}
+}
@@ -2,6 +2,8 @@ package validator
 
 import (
 	"regexp"
+	"strings"
+	"unicode"
 )
 
 var (
@@ -45,6 +47,11 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
+// Printable reports whether the value has no control character, like a line break
+func Printable(value string) bool {
+	return strings.IndexFunc(value, unicode.IsControl) < 0
+}
+
 func Unique(values []string) bool {
 	uniqueValues := make(map[string]bool)
 
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS team_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    team_id UUID NOT NULL,
+    team_version integer NOT NULL,
+    operation char varying(20) NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    delivered_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS team_events_pending_idx ON team_events (next_attempt_at) WHERE delivered_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS teams_unindexed_idx;
+ALTER TABLE teams DROP COLUMN IF EXISTS updated_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS teams_unindexed_idx ON teams (updated_at) WHERE is_indexed = false;
\ No newline at end of file
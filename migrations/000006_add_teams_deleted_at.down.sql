@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS teams_deleted_at_idx;
+DROP INDEX IF EXISTS teams_team_user_active_idx;
+ALTER TABLE teams ADD CONSTRAINT teams_team_user_key UNIQUE (team_user);
+ALTER TABLE teams DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
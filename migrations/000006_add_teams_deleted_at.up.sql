@@ -0,0 +1,4 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_team_user_key;
+CREATE UNIQUE INDEX IF NOT EXISTS teams_team_user_active_idx ON teams (team_user) WHERE is_deleted = false;
+CREATE INDEX IF NOT EXISTS teams_deleted_at_idx ON teams (deleted_at) WHERE is_deleted = true;
\ No newline at end of file
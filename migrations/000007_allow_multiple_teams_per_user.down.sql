@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS teams_team_user_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS teams_team_user_active_idx ON teams (team_user) WHERE is_deleted = false;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS teams_team_user_active_idx;
+CREATE INDEX IF NOT EXISTS teams_team_user_idx ON teams (team_user);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE team_members DROP CONSTRAINT IF EXISTS team_members_role_check;
+ALTER TABLE team_members DROP COLUMN IF EXISTS team_member_role;
\ No newline at end of file
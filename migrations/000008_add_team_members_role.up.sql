@@ -0,0 +1,2 @@
+ALTER TABLE team_members ADD COLUMN IF NOT EXISTS team_member_role char varying(20) NOT NULL DEFAULT 'member';
+ALTER TABLE team_members ADD CONSTRAINT team_members_role_check CHECK (team_member_role IN ('admin', 'member', 'viewer'));
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS team_invitations (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    team_invitation_team UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    team_invitation_inviter UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_invitation_email char varying(320) NOT NULL,
+    team_invitation_role char varying(20) NOT NULL DEFAULT 'member',
+    team_invitation_status char varying(20) NOT NULL DEFAULT 'pending',
+    team_invitation_expiry timestamp(0) with time zone NOT NULL,
+    token_hash bytea NOT NULL UNIQUE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS team_invitations_pending_idx ON team_invitations (team_invitation_team, team_invitation_email) WHERE team_invitation_status = 'pending';
+CREATE INDEX IF NOT EXISTS team_invitations_email_idx ON team_invitations (team_invitation_email);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS team_audit_logs;
+DROP TABLE IF EXISTS team_transfers;
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS team_transfers (
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    team_transfer_team UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    team_transfer_from UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_transfer_to UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_transfer_status char varying(20) NOT NULL DEFAULT 'pending',
+    resolved_at timestamp(0) with time zone
+);
+CREATE UNIQUE INDEX IF NOT EXISTS team_transfers_pending_idx ON team_transfers (team_transfer_team) WHERE team_transfer_status = 'pending';
+CREATE TABLE IF NOT EXISTS team_audit_logs (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    team_id UUID NOT NULL,
+    actor UUID NOT NULL,
+    action char varying(50) NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS team_audit_logs_team_idx ON team_audit_logs (team_id, created_at);
\ No newline at end of file
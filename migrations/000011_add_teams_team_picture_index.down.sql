@@ -0,0 +1 @@
+DROP INDEX IF EXISTS teams_team_picture_idx;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS teams_team_picture_idx ON teams (team_picture);
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    idempotency_key char varying(255) NOT NULL,
+    idempotency_user UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp(0) with time zone NOT NULL,
+    request_hash bytea,
+    response_status integer,
+    response_headers jsonb,
+    response_body bytea,
+    PRIMARY KEY (idempotency_user, idempotency_key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
\ No newline at end of file
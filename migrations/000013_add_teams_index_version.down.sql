@@ -0,0 +1 @@
+ALTER TABLE teams DROP COLUMN IF EXISTS index_version;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS index_version integer NOT NULL DEFAULT 1;
+UPDATE teams SET index_version = version;
\ No newline at end of file